@@ -1,15 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/keptn-sandbox/datadog-service/pkg/datadogtest"
+	"github.com/keptn-sandbox/datadog-service/pkg/metrics"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
 	"github.com/keptn/go-utils/pkg/lib/v0_2_0/fake"
 
 	keptn "github.com/keptn/go-utils/pkg/lib/keptn"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	logger "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
 )
@@ -17,7 +34,7 @@ import (
 /**
  * loads a cloud event from the passed test json file and initializes a keptn object with it
  */
-func initializeTestObjects(eventFileName string) (*keptnv2.Keptn, *cloudevents.Event, error) {
+func initializeTestObjects(eventFileName string, configurationServiceURL string) (*keptnv2.Keptn, *cloudevents.Event, error) {
 	// load sample event
 	eventFile, err := ioutil.ReadFile(eventFileName)
 	if err != nil {
@@ -35,15 +52,97 @@ func initializeTestObjects(eventFileName string) (*keptnv2.Keptn, *cloudevents.E
 		EventSender: &fake.EventSender{},
 	}
 	keptnOptions.UseLocalFileSystem = true
+	keptnOptions.ConfigurationServiceURL = configurationServiceURL
 	ddKeptn, err := keptnv2.NewKeptn(incomingEvent, keptnOptions)
 
 	return ddKeptn, incomingEvent, err
 }
 
-// Tests the HandleGetSliTriggeredEvent Handler
-// TODO: Add your test-code
+/**
+ * starts a fake Keptn resource service that serves the given resources by the end of their path,
+ * e.g. service/helloservice/resource/datadog/sli.yaml, stores the resources added to a service and responds with 404
+ * to all other requests
+ */
+func newResourceService(resources map[string]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			added := struct {
+				Resources []struct {
+					ResourceURI     string `json:"resourceURI"`
+					ResourceContent string `json:"resourceContent"`
+				} `json:"resources"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&added); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for _, resource := range added.Resources {
+				content, _ := base64.StdEncoding.DecodeString(resource.ResourceContent)
+				resources[r.URL.Path+"/"+strings.TrimPrefix(resource.ResourceURI, "/")] = string(content)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"version": "1"})
+			return
+		}
+
+		for suffix, content := range resources {
+			if strings.HasSuffix(r.URL.Path, suffix) {
+				json.NewEncoder(w).Encode(map[string]string{
+					"resourceURI":     suffix,
+					"resourceContent": base64.StdEncoding.EncodeToString([]byte(content)),
+				})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+/**
+ * points the handlers at the fake Datadog server for the duration of the test
+ */
+func useDatadogServer(t *testing.T, server *datadogtest.Server) {
+	t.Setenv("DD_API_KEY", "api-key")
+	t.Setenv("DD_APP_KEY", "app-key")
+	t.Setenv("DD_SITE", "")
+	server.APIKey, server.AppKey = "api-key", "app-key"
+
+	previous := apiClient
+	apiClient = server.APIClient()
+	t.Cleanup(func() { apiClient = previous })
+}
+
+/**
+ * collects the log output for the duration of the test
+ */
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var logs bytes.Buffer
+	previous := logger.StandardLogger().Out
+	logger.SetOutput(&logs)
+	t.Cleanup(func() { logger.SetOutput(previous) })
+	return &logs
+}
+
+// Tests the HandleGetSliTriggeredEvent Handler against a fake Datadog API
 func TestHandleGetSliTriggered(t *testing.T) {
-	ddKeptn, incomingEvent, err := initializeTestObjects("test/events/get-sli.triggered.json")
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+	datadogServer.ScriptQuery("avg:system.load.1{env:hardening,service:helloservice}", datadogtest.Metrics(datadogtest.Series{
+		Metric:   "system.load.1",
+		Interval: time.Minute,
+		Points:   []datadogtest.Point{{Time: time.Date(2021, 1, 15, 15, 9, 0, 0, time.UTC), Value: 0.42}},
+	}))
+
+	resourceService := newResourceService(map[string]string{
+		"service/helloservice/resource/datadog/sli.yaml": "indicators:\n  system_load: avg:system.load.1{env:$STAGE,service:$SERVICE}\n",
+	})
+	defer resourceService.Close()
+
+	ddKeptn, incomingEvent, err := initializeTestObjects("test/events/get-sli.triggered.json", resourceService.URL)
 	if err != nil {
 		t.Error(err)
 		return
@@ -76,4 +175,620 @@ func TestHandleGetSliTriggered(t *testing.T) {
 	if keptnv2.GetFinishedEventType(keptnv2.GetSLITaskName) != ddKeptn.EventSender.(*fake.EventSender).SentEvents[1].Type() {
 		t.Errorf("Expected a get-sli.finished event type")
 	}
+
+	finishedData := &keptnv2.GetSLIFinishedEventData{}
+	if err := ddKeptn.EventSender.(*fake.EventSender).SentEvents[1].DataAs(finishedData); err != nil {
+		t.Fatalf("Error getting the get-sli.finished event data: %v", err)
+	}
+	if finishedData.Status != keptnv2.StatusSucceeded || len(finishedData.GetSLI.IndicatorValues) != 1 {
+		t.Fatalf("Expected a succeeded event with one indicator value, but got %+v", finishedData)
+	}
+	if value := finishedData.GetSLI.IndicatorValues[0]; value.Metric != "system_load" || value.Value != 0.42 || !value.Success {
+		t.Errorf("Expected system_load with value 0.42, but got %+v", value)
+	}
+	if requests := datadogServer.Requests(http.MethodGet, "/api/v1/query"); len(requests) != 1 {
+		t.Errorf("Expected one query sent to Datadog, but got %d", len(requests))
+	}
+}
+
+// Tests that the last response is reported with a warning if the indicator timeout is reached while waiting for the data
+func TestHandleGetSliTriggeredTimeoutWhileWaitingForData(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+	// the point doesn't cover the end of the evaluation window, so the data is polled again after a minute
+	datadogServer.ScriptQuery("avg:system.load.1{env:hardening,service:helloservice}", datadogtest.Metrics(datadogtest.Series{
+		Metric:   "system.load.1",
+		Interval: time.Minute,
+		Points:   []datadogtest.Point{{Time: time.Date(2021, 1, 15, 15, 5, 0, 0, time.UTC), Value: 0.42}},
+	}))
+
+	previousEnv := env
+	env.DataPollIntervalInSeconds = 60
+	env.MaxDataWaitInSeconds = 120
+	t.Cleanup(func() { env = previousEnv })
+	logs := captureLogs(t)
+
+	resourceService := newResourceService(map[string]string{
+		"service/helloservice/resource/datadog/sli.yaml": "spec_version: '2.0'\nindicators:\n  system_load:\n    query: avg:system.load.1{env:$STAGE,service:$SERVICE}\n    timeout: 500ms\n",
+	})
+	defer resourceService.Close()
+
+	ddKeptn, incomingEvent, err := initializeTestObjects("test/events/get-sli.triggered.json", resourceService.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specificEvent := &keptnv2.GetSLITriggeredEventData{}
+	if err := incomingEvent.DataAs(specificEvent); err != nil {
+		t.Fatalf("Error getting keptn event data: %v", err)
+	}
+
+	if err := HandleGetSliTriggeredEvent(ddKeptn, *incomingEvent, specificEvent); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	finishedData := &keptnv2.GetSLIFinishedEventData{}
+	if err := ddKeptn.EventSender.(*fake.EventSender).SentEvents[1].DataAs(finishedData); err != nil {
+		t.Fatalf("Error getting the get-sli.finished event data: %v", err)
+	}
+	if len(finishedData.GetSLI.IndicatorValues) != 1 {
+		t.Fatalf("Expected one indicator value, but got %+v", finishedData)
+	}
+	if value := finishedData.GetSLI.IndicatorValues[0]; value.Value != 0.42 || !value.Success {
+		t.Errorf("Expected system_load with the last value 0.42, but got %+v", value)
+	}
+	if !strings.Contains(logs.String(), "data was not ready when the timeout was reached, using the last response: 0.42") {
+		t.Errorf("Expected a warning with the last value, but got logs:\n%s", logs.String())
+	}
+}
+
+// Tests that the Datadog keys are still masked in the logs after the logger was configured for an event
+func TestHandleGetSliTriggeredRedactsKeys(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+	t.Setenv("DD_API_KEY", "0123456789abcdef")
+	datadogServer.APIKey = "0123456789abcdef"
+
+	previousFormatter := logger.StandardLogger().Formatter
+	t.Cleanup(func() { logger.SetFormatter(previousFormatter) })
+	logs := captureLogs(t)
+
+	resourceService := newResourceService(map[string]string{
+		"service/helloservice/resource/datadog/sli.yaml": "indicators:\n  system_load: avg:system.load.1{env:$STAGE,service:$SERVICE}\n",
+	})
+	defer resourceService.Close()
+
+	ddKeptn, incomingEvent, err := initializeTestObjects("test/events/get-sli.triggered.json", resourceService.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specificEvent := &keptnv2.GetSLITriggeredEventData{}
+	if err := incomingEvent.DataAs(specificEvent); err != nil {
+		t.Fatalf("Error getting keptn event data: %v", err)
+	}
+
+	if err := HandleGetSliTriggeredEvent(ddKeptn, *incomingEvent, specificEvent); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	logger.Errorf("request failed with the key 0123456789abcdef")
+
+	if strings.Contains(logs.String(), "0123456789abcdef") || !strings.Contains(logs.String(), "request failed with the key [REDACTED]") {
+		t.Errorf("Expected the API key to be masked, but got logs:\n%s", logs.String())
+	}
+}
+
+const testShipyard = `apiVersion: spec.keptn.sh/0.2.0
+kind: Shipyard
+metadata:
+  name: shipyard-podtatohead
+spec:
+  stages:
+    - name: hardening
+    - name: production
+`
+
+const testSLO = `spec_version: "1.0"
+objectives:
+  - sli: response_time_p95
+    displayName: Response time P95
+    pass:
+      - criteria:
+          - "<=600"
+    warning:
+      - criteria:
+          - "<=800"
+  - sli: throughput
+    pass:
+      - criteria:
+          - "<=+10%"
+total_score:
+  pass: "90%"
+  warning: "75%"
+`
+
+/**
+ * handles test/events/configure-monitoring.triggered.json and returns the data of the configure-monitoring.finished event
+ */
+func handleConfigureMonitoring(t *testing.T, configurationServiceURL string) *keptnv2.ConfigureMonitoringFinishedEventData {
+	ddKeptn, incomingEvent, err := initializeTestObjects("test/events/configure-monitoring.triggered.json", configurationServiceURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specificEvent := &keptnv2.ConfigureMonitoringTriggeredEventData{}
+	if err := incomingEvent.DataAs(specificEvent); err != nil {
+		t.Fatalf("Error getting keptn event data: %v", err)
+	}
+
+	if err := HandleConfigureMonitoringTriggeredEvent(ddKeptn, *incomingEvent, specificEvent); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	sentEvents := ddKeptn.EventSender.(*fake.EventSender).SentEvents
+	if len(sentEvents) != 2 || sentEvents[1].Type() != keptnv2.GetFinishedEventType(keptnv2.ConfigureMonitoringTaskName) {
+		t.Fatalf("Expected a started and a finished event, but got %v", sentEvents)
+	}
+	finishedData := &keptnv2.ConfigureMonitoringFinishedEventData{}
+	if err := sentEvents[1].DataAs(finishedData); err != nil {
+		t.Fatalf("Error getting the configure-monitoring.finished event data: %v", err)
+	}
+	return finishedData
+}
+
+// Tests that HandleConfigureMonitoringTriggeredEvent creates a monitor per objective in every stage of the shipyard
+// and a dashboard of the service, and that configuring the service again leaves the monitors unchanged
+func TestHandleConfigureMonitoringTriggered(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+
+	previousEnv := env
+	env.CreateMonitors = true
+	env.CreateDashboards = true
+	t.Cleanup(func() { env = previousEnv })
+
+	resourceService := newResourceService(map[string]string{
+		"project/podtatohead/resource/shipyard.yaml":                      testShipyard,
+		"stage/hardening/service/helloservice/resource/slo.yaml":          testSLO,
+		"stage/hardening/service/helloservice/resource/datadog/sli.yaml":  "indicators:\n  response_time_p95: avg:trace.http.request.duration{service:$SERVICE}\n",
+		"stage/production/service/helloservice/resource/datadog/sli.yaml": "indicators:\n  response_time_p95: avg:trace.http.request.duration{service:$SERVICE}\n",
+	})
+	defer resourceService.Close()
+
+	for run := 0; run < 2; run++ {
+		finishedData := handleConfigureMonitoring(t, resourceService.URL)
+		if finishedData.Status != keptnv2.StatusSucceeded || finishedData.Stage != "" || finishedData.Service != "helloservice" {
+			t.Errorf("Expected a succeeded event for the service helloservice, but got %+v", finishedData.EventData)
+		}
+
+		expected := "hardening: monitors: created 1, updated 0, unchanged 0, skipped throughput"
+		if run > 0 {
+			expected = "hardening: monitors: created 0, updated 0, unchanged 1, skipped throughput"
+		}
+		if !strings.Contains(finishedData.Message, expected) || !strings.Contains(finishedData.Message, "production: no objectives in slo.yaml") {
+			t.Errorf("Expected the message to contain '%s', but got '%s'", expected, finishedData.Message)
+		}
+
+		dashboards := datadogServer.Dashboards()
+		if len(dashboards) != 1 {
+			t.Fatalf("Expected one dashboard, but got %d", len(dashboards))
+		}
+		url := "https://app.datadoghq.com" + fmt.Sprint(dashboards[0]["url"])
+		if finishedData.Labels[dashboardLabel] != url || !strings.Contains(finishedData.Message, url) {
+			t.Errorf("Expected the dashboard URL %s in the labels and the message, but got %v and '%s'", url, finishedData.Labels, finishedData.Message)
+		}
+	}
+
+	dashboard := datadogServer.Dashboards()[0]
+	if title := dashboard["title"]; title != "Keptn: podtatohead / helloservice" {
+		t.Errorf("Unexpected dashboard title %v", title)
+	}
+	if widgets, ok := dashboard["widgets"].([]interface{}); !ok || len(widgets) != 1 {
+		t.Errorf("Expected a widget for response_time_p95, but got %v", dashboard["widgets"])
+	}
+
+	monitors := datadogServer.Monitors()
+	if len(monitors) != 1 {
+		t.Fatalf("Expected one monitor, but got %d", len(monitors))
+	}
+	if query := monitors[0]["query"]; query != "last(last_5m):avg:trace.http.request.duration{service:helloservice} > 800" {
+		t.Errorf("Unexpected monitor query %v", query)
+	}
+	for _, tag := range []string{"keptn_project:podtatohead", "keptn_stage:hardening", "keptn_service:helloservice", "keptn_sli:response_time_p95"} {
+		if !strings.Contains(fmt.Sprint(monitors[0]["tags"]), tag) {
+			t.Errorf("Expected the monitor to be tagged with %s, but got %v", tag, monitors[0]["tags"])
+		}
+	}
+}
+
+// Tests that HandleConfigureMonitoringTriggeredEvent mirrors the objectives as Datadog SLOs
+// and deletes the SLOs once their objectives are removed
+func TestHandleConfigureMonitoringCreatesSLOs(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+
+	previousEnv := env
+	env.CreateMonitors = true
+	env.CreateSLOs = true
+	env.SLOTimeframe = "7d"
+	env.SLOMonitorTarget = 99.5
+	env.CreateDashboards = false
+	t.Cleanup(func() { env = previousEnv })
+
+	sliConfig := `spec_version: '2.0'
+indicators:
+  response_time_p95: avg:trace.http.request.duration{service:$SERVICE}
+  throughput:
+    api_version: v2
+    queries:
+      errors: sum:trace.http.request.errors{service:$SERVICE}.as_count()
+      requests: sum:trace.http.request.hits{service:$SERVICE}.as_count()
+    formula: errors / requests
+`
+	resources := map[string]string{
+		"project/podtatohead/resource/shipyard.yaml":                     testShipyard,
+		"stage/hardening/service/helloservice/resource/slo.yaml":         testSLO,
+		"stage/hardening/service/helloservice/resource/datadog/sli.yaml": sliConfig,
+	}
+	resourceService := newResourceService(resources)
+	defer resourceService.Close()
+
+	finishedData := handleConfigureMonitoring(t, resourceService.URL)
+	if expected := "hardening: monitors: created 1, updated 0, unchanged 0, skipped throughput"; !strings.Contains(finishedData.Message, expected) {
+		t.Errorf("Expected the message to contain '%s', but got '%s'", expected, finishedData.Message)
+	}
+	if expected := "SLOs: created 1, updated 0, unchanged 0, skipped throughput"; !strings.Contains(finishedData.Message, expected) {
+		t.Errorf("Expected the message to contain '%s', but got '%s'", expected, finishedData.Message)
+	}
+
+	slos := datadogServer.SLOs()
+	if len(slos) != 1 {
+		t.Fatalf("Expected one SLO, but got %d", len(slos))
+	}
+	monitorID := datadogServer.Monitors()[0]["id"]
+	if slos[0]["type"] != "monitor" || fmt.Sprint(slos[0]["monitor_ids"]) != fmt.Sprintf("[%v]", monitorID) {
+		t.Errorf("Expected a monitor-based SLO of monitor %v, but got %v", monitorID, slos[0])
+	}
+
+	delete(resources, "stage/hardening/service/helloservice/resource/slo.yaml")
+	finishedData = handleConfigureMonitoring(t, resourceService.URL)
+	if expected := "hardening: no objectives in slo.yaml, monitors: created 0, updated 0, unchanged 0, deleted 1, SLOs: created 0, updated 0, unchanged 0, deleted 1"; !strings.Contains(finishedData.Message, expected) {
+		t.Errorf("Expected the message to contain '%s', but got '%s'", expected, finishedData.Message)
+	}
+	if slos := datadogServer.SLOs(); len(slos) != 0 {
+		t.Errorf("Expected the SLO to be deleted, but got %v", slos)
+	}
+	if monitors := datadogServer.Monitors(); len(monitors) != 0 {
+		t.Errorf("Expected the monitor to be deleted, but got %v", monitors)
+	}
+}
+
+// Tests that HandleConfigureMonitoringTriggeredEvent adds the missing starter resources of the service, so the
+// monitors of the starter objectives are created right away, and that existing resources are kept
+func TestHandleConfigureMonitoringSeedsResources(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+
+	previousEnv := env
+	env.SeedResources = true
+	env.CreateMonitors = true
+	env.CreateDashboards = false
+	t.Cleanup(func() { env = previousEnv })
+
+	resources := map[string]string{
+		"project/podtatohead/resource/shipyard.yaml":             testShipyard,
+		"stage/hardening/service/helloservice/resource/slo.yaml": testSLO,
+	}
+	resourceService := newResourceService(resources)
+	defer resourceService.Close()
+
+	finishedData := handleConfigureMonitoring(t, resourceService.URL)
+	for _, expected := range []string{
+		"hardening: added starter datadog/sli.yaml, monitors: created 1",
+		"production: added starter datadog/sli.yaml and slo.yaml, monitors: created 1",
+	} {
+		if !strings.Contains(finishedData.Message, expected) {
+			t.Errorf("Expected the message to contain '%s', but got '%s'", expected, finishedData.Message)
+		}
+	}
+
+	if slo := resources["stage/hardening/service/helloservice/resource/slo.yaml"]; slo != testSLO {
+		t.Errorf("Expected the existing slo.yaml to be kept, but got %s", slo)
+	}
+	sliConfig := resources["/v1/project/podtatohead/stage/production/service/helloservice/resource/datadog/sli.yaml"]
+	if !strings.Contains(sliConfig, "p95:trace.http.request{env:production,service:helloservice}") {
+		t.Errorf("Expected a starter datadog/sli.yaml of helloservice in production, but got '%s'", sliConfig)
+	}
+	if monitors := datadogServer.Monitors(); len(monitors) != 2 {
+		t.Errorf("Expected a monitor per stage, but got %d", len(monitors))
+	}
+
+	finishedData = handleConfigureMonitoring(t, resourceService.URL)
+	if strings.Contains(finishedData.Message, "added starter") {
+		t.Errorf("Expected no resources to be added again, but got '%s'", finishedData.Message)
+	}
+}
+
+// Tests that HandleTaskFinishedEvent posts an evaluation.finished event to the Datadog event stream with the tags of
+// the service, its version, the Keptn context and the result
+func TestHandleTaskFinishedEvent(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+
+	_, incomingEvent, err := initializeTestObjects("test/events/evaluation.finished.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	specificEvent := &keptnv2.EvaluationFinishedEventData{}
+	if err := incomingEvent.DataAs(specificEvent); err != nil {
+		t.Fatalf("Error getting keptn event data: %v", err)
+	}
+
+	if err := HandleTaskFinishedEvent(*incomingEvent, keptnv2.EvaluationTaskName, specificEvent.EventData, evaluationDetails(specificEvent.Evaluation)); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	events := datadogServer.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected one Datadog event, but got %d", len(events))
+	}
+	if title := events[0]["title"]; title != "Keptn evaluation of helloservice v0.1.1 in hardening (podtatohead): warning" {
+		t.Errorf("Unexpected event title %v", title)
+	}
+	if alertType := events[0]["alert_type"]; alertType != "warning" {
+		t.Errorf("Expected the alert type warning, but got %v", alertType)
+	}
+	if text := fmt.Sprint(events[0]["text"]); !strings.Contains(text, "Score: 80") || !strings.Contains(text, "response_time_p95: 512.5 (pass)") {
+		t.Errorf("Expected the score and the indicator results in the text, but got '%s'", text)
+	}
+	for _, tag := range []string{"keptn_project:podtatohead", "keptn_stage:hardening", "keptn_service:helloservice", "version:v0.1.1",
+		"keptn_context:da7aec34-78c4-4182-a2c8-51eb88f5871d", "keptn_result:warning"} {
+		if !strings.Contains(fmt.Sprint(events[0]["tags"]), tag) {
+			t.Errorf("Expected the event to be tagged with %s, but got %v", tag, events[0]["tags"])
+		}
+	}
+
+	datadogServer.Script(http.MethodPost, "/api/v1/events", datadogtest.Error(http.StatusForbidden, "Forbidden"))
+	if err := HandleTaskFinishedEvent(*incomingEvent, keptnv2.EvaluationTaskName, specificEvent.EventData, nil); err == nil {
+		t.Error("Expected an error if Datadog rejects the event")
+	}
+}
+
+// Tests that finished events are only posted for projects whose SLI provider is served and that have credentials
+func TestHandleTaskFinishedEventSelectsProjects(t *testing.T) {
+	datadogServer := datadogtest.NewServer()
+	defer datadogServer.Close()
+	useDatadogServer(t, datadogServer)
+
+	previousEnv := env
+	env.Instances = []string{"eu"}
+	t.Cleanup(func() { env = previousEnv })
+
+	lighthouseConfig := func(name, provider string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name}, Data: map[string]string{"sli-provider": provider}}
+	}
+	useLighthouseConfigs := func(configMaps ...*corev1.ConfigMap) {
+		clientset := k8sfake.NewSimpleClientset()
+		for _, configMap := range configMaps {
+			if _, err := clientset.CoreV1().ConfigMaps("").Create(context.Background(), configMap, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		lighthouseConfigs = clientset.CoreV1()
+	}
+	t.Cleanup(func() { lighthouseConfigs = nil })
+
+	_, incomingEvent, err := initializeTestObjects("test/events/evaluation.finished.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	specificEvent := &keptnv2.EvaluationFinishedEventData{}
+	if err := incomingEvent.DataAs(specificEvent); err != nil {
+		t.Fatalf("Error getting keptn event data: %v", err)
+	}
+	handle := func() {
+		if err := HandleTaskFinishedEvent(*incomingEvent, keptnv2.EvaluationTaskName, specificEvent.EventData, nil); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	// projects without an SLI provider and projects using another one are ignored
+	useLighthouseConfigs()
+	handle()
+	useLighthouseConfigs(lighthouseConfig("lighthouse-config-podtatohead", "dynatrace"), lighthouseConfig("lighthouse-config", "datadog"))
+	handle()
+	if events := datadogServer.Events(); len(events) != 0 {
+		t.Fatalf("Expected no Datadog event, but got %v", events)
+	}
+
+	// the events of a named instance are posted with its credentials, projects without credentials are ignored
+	useLighthouseConfigs(lighthouseConfig("lighthouse-config", "datadog-eu"))
+	handle()
+	if events := datadogServer.Events(); len(events) != 0 {
+		t.Fatalf("Expected no Datadog event without credentials of the instance, but got %v", events)
+	}
+
+	t.Setenv("EU_DD_API_KEY", "eu-api-key")
+	t.Setenv("EU_DD_APP_KEY", "eu-app-key")
+	datadogServer.APIKey, datadogServer.AppKey = "eu-api-key", "eu-app-key"
+	handle()
+	if events := datadogServer.Events(); len(events) != 1 {
+		t.Fatalf("Expected one Datadog event, but got %d", len(events))
+	}
+}
+
+// Tests that runConcurrently calls the function once per index without exceeding the limit
+func TestRunConcurrently(t *testing.T) {
+	const n, limit = 20, 3
+
+	var inFlight, maxInFlight int32
+	calls := make([]int32, n)
+
+	runConcurrently(n, limit, func(i int) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&calls[i], 1)
+		atomic.AddInt32(&inFlight, -1)
+	})
+
+	for i, c := range calls {
+		if c != 1 {
+			t.Errorf("Expected index %d to be processed once, but got %d calls", i, c)
+		}
+	}
+
+	if maxInFlight > limit {
+		t.Errorf("Expected at most %d concurrent calls, but got %d", limit, maxInFlight)
+	}
+}
+
+// Tests that the message of a failed SLI result names the cause of the failure
+func TestFailedSLIResult(t *testing.T) {
+	tests := []struct {
+		err     error
+		message string
+	}{
+		{err: fmt.Errorf("query returned: %w", sli.ErrNoDataPoints), message: "empty response"},
+		{err: &metrics.APIError{StatusCode: http.StatusForbidden, Err: errors.New("403 Forbidden")}, message: "auth failure"},
+		{err: &metrics.APIError{StatusCode: http.StatusBadRequest, Err: errors.New("400 Bad Request")}, message: "API error"},
+		{err: errors.New("connection refused"), message: "API error"},
+		{err: fmt.Errorf("%w: unexpected EOF", errInvalidQuery), message: "invalid query"},
+		{err: fmt.Errorf("Get \"https://api.datadoghq.com\": %w", context.DeadlineExceeded), message: "timeout"},
+	}
+
+	for _, tt := range tests {
+		result := failedSLIResult("response_time", tt.err)
+
+		if result.Success {
+			t.Errorf("Expected a failed SLI result for %v", tt.err)
+		}
+		if result.Metric != "response_time" {
+			t.Errorf("Expected metric response_time, but got %s", result.Metric)
+		}
+		if !strings.HasPrefix(result.Message, tt.message) {
+			t.Errorf("Expected message starting with '%s', but got '%s'", tt.message, result.Message)
+		}
+	}
+}
+
+// fakeBackend returns a series with a single point of the value for the query, or err
+type fakeBackend struct {
+	values  map[string]float64
+	err     error
+	queries []string
+}
+
+func (b *fakeBackend) Query(_ context.Context, indicator sli.Indicator, start, end time.Time) ([]sli.Series, *http.Response, error) {
+	b.queries = append(b.queries, indicator.Query)
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+	value, ok := b.values[indicator.Query]
+	if !ok {
+		return []sli.Series{}, nil, nil
+	}
+	timestamp := float64(end.Unix() * 1000)
+	return []sli.Series{{Points: [][]*float64{{&timestamp, &value}}}}, nil, nil
+}
+
+// Tests that indicators are evaluated with the values of the metrics backend
+func TestEvaluateIndicatorWithFakeBackend(t *testing.T) {
+	backend := &fakeBackend{values: map[string]float64{
+		"avg:trace.http.request.duration{env:production,service:carts}": 0.25,
+	}}
+	querier := indicatorQuerier{backend: backend, poller: metrics.Poller{Clock: metrics.RealClock}, retrier: metrics.Retrier{Clock: metrics.RealClock}}
+
+	start, end := time.Unix(1000, 0), time.Unix(1600, 0)
+	queryContext := sli.NewQueryContext("sockshop", "production", "carts", start, end)
+	indicator := sli.Indicator{
+		Query:          "avg:trace.http.request.duration{env:$STAGE,service:$SERVICE}",
+		UnitConversion: &sli.UnitConversion{From: "s", To: "ms"},
+	}
+
+	value := evaluateIndicator(context.Background(), querier, "response_time", indicator, queryContext, start, end)
+	if value.err != nil {
+		t.Fatalf("Expected no error, but got %v", value.err)
+	}
+	if value.value != 250 {
+		t.Errorf("Expected 250, but got %v", value.value)
+	}
+
+	value = evaluateIndicator(context.Background(), querier, "throughput", sli.Indicator{Query: "sum:trace.http.request.hits{*}", MissingData: sli.MissingDataZero}, queryContext, start, end)
+	if value.err != nil || value.value != 0 || value.policy != sli.MissingDataZero {
+		t.Errorf("Expected 0 reported by the missing_data policy, but got %+v", value)
+	}
+
+	backend.err = &metrics.APIError{StatusCode: http.StatusForbidden, Err: errors.New("403 Forbidden")}
+	value = evaluateIndicator(context.Background(), querier, "response_time", indicator, queryContext, start, end)
+	if result := value.sliResult(); result.Success || !strings.HasPrefix(result.Message, "auth failure") {
+		t.Errorf("Expected a failed result with an auth failure, but got %+v", result)
+	}
+}
+
+func TestChangeSLIResult(t *testing.T) {
+	result := changeSLIResult("response_time_change", indicatorValue{metric: "response_time", value: 120}, indicatorValue{metric: "response_time_baseline", value: 100})
+	if !result.Success || result.Metric != "response_time_change" || result.Value != 20 {
+		t.Errorf("Expected a change of 20%%, but got %v", result)
+	}
+
+	result = changeSLIResult("response_time_change", indicatorValue{metric: "response_time", value: 120}, indicatorValue{metric: "response_time_baseline", value: 0})
+	if result.Success {
+		t.Errorf("Expected a failed SLI result for a baseline of 0, but got %v", result)
+	}
+
+	noData := fmt.Errorf("query returned: %w", sli.ErrNoDataPoints)
+	result = changeSLIResult("response_time_change", indicatorValue{metric: "response_time", value: 120}, indicatorValue{metric: "response_time_baseline", err: noData})
+	if result.Success || !strings.HasPrefix(result.Message, "empty response") {
+		t.Errorf("Expected a failed SLI result because of the missing baseline, but got %v", result)
+	}
+
+	result = changeSLIResult("response_time_change", indicatorValue{metric: "response_time", err: noData, policy: sli.MissingDataSkip}, indicatorValue{metric: "response_time_baseline", value: 100})
+	if result != nil {
+		t.Errorf("Expected the change to be left out, but got %v", result)
+	}
+}
+
+func TestParseProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		instance string
+		ok       bool
+	}{
+		{provider: "datadog", instance: "", ok: true},
+		{provider: "datadog-eu", instance: "eu", ok: true},
+		{provider: "datadog/us5", instance: "us5", ok: true},
+		{provider: "Datadog-EU", instance: "eu", ok: true},
+		{provider: "datadog-", ok: false},
+		{provider: "dynatrace", ok: false},
+		{provider: "prometheus", ok: false},
+	}
+
+	for _, tt := range tests {
+		instance, ok := parseProvider(tt.provider)
+		if instance != tt.instance || ok != tt.ok {
+			t.Errorf("Expected (%s, %v) for %s, but got (%s, %v)", tt.instance, tt.ok, tt.provider, instance, ok)
+		}
+	}
+}
+
+func TestServesProvider(t *testing.T) {
+	previous := env.Instances
+	env.Instances = []string{"eu", "us5"}
+	defer func() { env.Instances = previous }()
+
+	for provider, expected := range map[string]bool{"datadog": true, "datadog-eu": true, "datadog/us5": true, "datadog-us3": false, "dynatrace": false} {
+		if servesProvider(provider) != expected {
+			t.Errorf("Expected servesProvider(%s) to be %v", provider, expected)
+		}
+	}
 }
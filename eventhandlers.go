@@ -2,49 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
+	"github.com/keptn-sandbox/datadog-service/pkg/credentials"
+	"github.com/keptn-sandbox/datadog-service/pkg/metrics"
+	"github.com/keptn-sandbox/datadog-service/pkg/monitoring"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
 	"github.com/keptn-sandbox/datadog-service/pkg/utils"
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
 	logger "github.com/sirupsen/logrus"
 )
 
 const (
-	sliFile                        = "datadog/sli.yaml"
-	defaultSleepBeforeAPIInSeconds = 60
+	sliFile = monitoring.SLIFile
 )
 
-// We have to put a min of 60s of sleep for the datadog API to reflect the data correctly
-// More info: https://github.com/keptn-sandbox/datadog-service/issues/8
-var sleepBeforeAPIInSeconds int
-
-func init() {
-	var err error
-	sleepBeforeAPIInSeconds, err = strconv.Atoi(strings.TrimSpace(os.Getenv("SLEEP_BEFORE_API_IN_SECONDS")))
-	if err != nil || sleepBeforeAPIInSeconds < defaultSleepBeforeAPIInSeconds {
-		logger.Infof("defaulting SLEEP_BEFORE_API_IN_SECONDS to 60s because it was set to '%v' which is less than the min allowed value of 60s", sleepBeforeAPIInSeconds)
-		sleepBeforeAPIInSeconds = defaultSleepBeforeAPIInSeconds
-	}
-}
-
-// HandleGetSliTriggeredEvent handles get-sli.triggered events if SLIProvider == datadog
+// HandleGetSliTriggeredEvent handles get-sli.triggered events if SLIProvider is datadog or a configured Datadog instance
 func HandleGetSliTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.GetSLITriggeredEventData) error {
 	var shkeptncontext string
 	_ = incomingEvent.Context.ExtensionAs("shkeptncontext", &shkeptncontext)
 	configureLogger(incomingEvent.Context.GetID(), shkeptncontext)
 
 	logger.Infof("Handling get-sli.triggered Event: %s", incomingEvent.Context.GetID())
+	received := time.Now()
 
 	// Step 1 - Do we need to do something?
 	// Lets make sure we are only processing an event that really belongs to our SLI Provider
-	if data.GetSLI.SLIProvider != "datadog" {
+	if !servesProvider(data.GetSLI.SLIProvider) {
 		logger.Infof("Not handling get-sli event as it is meant for %s", data.GetSLI.SLIProvider)
 		return nil
 	}
@@ -81,11 +75,10 @@ func HandleGetSliTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevent
 	// Step 5 - get SLI Config File
 	// Get SLI File from datadog subdirectory of the config repo - to add the file use:
 	//   keptn add-resource --project=PROJECT --stage=STAGE --service=SERVICE --resource=my-sli-config.yaml  --resourceUri=datadog/sli.yaml
-	sliConfig, err := ddKeptn.GetSLIConfiguration(data.Project, data.Stage, data.Service, sliFile)
+	// Indicators that aren't defined in any sli.yaml, or all of them if there is none, are the built-in default indicators
+	sliConfig, err := sli.GetConfiguration(ddKeptn.ResourceHandler, data.Project, data.Stage, data.Service, sliFile)
 	logger.Debugf("SLI config: %v", sliConfig)
 
-	// FYI you do not need to "fail" if sli.yaml is missing, you can also assume smart defaults like we do
-	// in keptn-contrib/dynatrace-service and keptn-contrib/prometheus-service
 	if err != nil {
 		// failed to fetch sli config file
 		errMsg := fmt.Sprintf("Failed to fetch SLI file %s from config repo: %s", sliFile, err.Error())
@@ -93,9 +86,10 @@ func HandleGetSliTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevent
 		// send a get-sli.finished event with status=error and result=failed back to Keptn
 
 		_, err = ddKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
-			Status: keptnv2.StatusErrored,
-			Result: keptnv2.ResultFailed,
-			Labels: labels,
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Labels:  labels,
+			Message: errMsg,
 		}, ServiceName)
 
 		return err
@@ -106,45 +100,168 @@ func HandleGetSliTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevent
 	// SLIResult: this is the array that will receive the results
 	indicators := data.GetSLI.Indicators
 	sliResults := []*keptnv2.SLIResult{}
-	ctx := datadog.NewDefaultContext(context.Background())
-	configuration := datadog.NewConfiguration()
-	apiClient := datadog.NewAPIClient(configuration)
+
+	ddCredentials, err := credentials.Lookup(context.Background(), instanceCredentialsProvider(data.GetSLI.SLIProvider), data.Project, data.Stage)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to get the Datadog credentials: %s", err.Error())
+		logger.Error(errMsg)
+
+		_, err = ddKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Labels:  labels,
+			Message: errMsg,
+		}, ServiceName)
+
+		return err
+	}
+	// credentials from secrets and files are only known now, so they are masked in the logs from here on
+	redactor.AddSecrets(ddCredentials.APIKey, ddCredentials.AppKey)
+	ctx := ddCredentials.Context(context.Background())
+
+	// the whole event is limited by the timeout of the SLI configuration or GET_SLI_TIMEOUT_IN_SECONDS,
+	// indicators that are still running afterwards are cancelled
+	timeout := time.Second * time.Duration(env.GetSliTimeoutInSeconds)
+	if sliConfig.Timeout > 0 {
+		timeout = sliConfig.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, received.Add(timeout))
+		defer cancel()
+	}
+	backend := metricsBackend
+	if backend == nil {
+		backend = metrics.Datadog{Client: datadogClient()}
+	}
 
 	logger.Debug("indicators:", indicators)
-	errored := false
 
-	for _, indicatorName := range indicators {
-		// Pulling the data from Datadog api immediately gives incorrect data in api response
-		// we have to wait for some time for the correct data to be reflected in the api response
-		// TODO: Find a better way around the sleep time for datadog api
-		logger.Debugf("waiting for %vs so that the metrics data is reflected correctly in the api", sleepBeforeAPIInSeconds)
-		time.Sleep(time.Second * time.Duration(sleepBeforeAPIInSeconds))
+	// Pulling the data from Datadog api immediately gives incorrect data in api response
+	// so every query is repeated until the data is reflected correctly in the api response
+	querier := indicatorQuerier{
+		backend: backend,
+		poller: metrics.Poller{
+			Clock:    metrics.RealClock,
+			Interval: time.Second * time.Duration(env.DataPollIntervalInSeconds),
+			MaxWait:  time.Second * time.Duration(env.MaxDataWaitInSeconds),
+		},
+		retrier: metrics.Retrier{
+			Clock:       metrics.RealClock,
+			MaxAttempts: env.MaxQueryAttempts,
+			BaseDelay:   time.Second * time.Duration(env.RetryBaseDelayInSeconds),
+			MaxDelay:    time.Second * time.Duration(env.RetryMaxDelayInSeconds),
+			RateLimit:   rateLimits.For(ddCredentials.Site + "/" + ddCredentials.APIKey),
+		},
+	}
 
-		query := replaceQueryParameters(data, sliConfig[indicatorName], start, end)
-		logger.Debugf("actual query sent to datadog: %v, from: %v, to: %v", query, start.Unix(), end.Unix())
-		resp, r, err := apiClient.MetricsApi.QueryMetrics(ctx, start.Unix(), end.Unix(), query)
-		if err != nil {
-			logger.Errorf("'%s': error getting value for the query: %v : %v\n", query, resp, err)
-			logger.Errorf("'%s': full HTTP response: %v\n", query, r)
-			errored = true
-			continue
+	// values that can be used in the indicator queries
+	queryContext := sli.NewQueryContext(data.Project, data.Stage, data.Service, start, end)
+	queryContext.Deployment = data.Deployment
+	queryContext.KeptnContext = ddKeptn.KeptnContext
+	queryContext.Labels = labels
+	for _, filter := range data.GetSLI.CustomFilters {
+		if filter != nil {
+			queryContext.Filters[filter.Key] = filter.Value
 		}
+	}
 
-		logger.Debugf("response from the metrics api: %v", resp)
+	// results are stored by index so that the order of the requested indicators is kept
+	results := make([][]*keptnv2.SLIResult, len(indicators))
+	// indicators without data that fail the whole evaluation because of missing_data: fail
+	failEvaluation := make([]bool, len(indicators))
+	// missing_data policies applied to indicators without data
+	appliedPolicies := make([][]string, len(indicators))
+	// indicators that could not be evaluated because the event timed out
+	timedOut := make([]bool, len(indicators))
+
+	runConcurrently(len(indicators), env.MaxConcurrentQueries, func(i int) {
+		indicatorName := indicators[i]
+		baseName := indicatorName
+		if ctx.Err() != nil {
+			logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Warnf("not querying the indicator: %v", ctx.Err())
+			timedOut[i] = true
+			results[i] = []*keptnv2.SLIResult{{
+				Metric:  indicatorName,
+				Success: false,
+				Message: fmt.Sprintf("timeout: the get-sli timeout of %v was reached before the indicator was queried", timeout),
+			}}
+			return
+		}
 
-		if len((*resp.Series)) != 0 {
-			points := *((*resp.Series)[0].Pointlist)
-			sliResult := &keptnv2.SLIResult{
+		indicatorConfig, ok := sliConfig.Indicators[indicatorName]
+		if !ok {
+			// <name>_baseline and <name>_change are computed from the indicator that defines the baseline
+			baseName, indicatorConfig, ok = sli.BaselineIndicator(sliConfig.Indicators, indicatorName)
+		}
+		if !ok {
+			logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Errorf("indicator is not defined in %s", sliFile)
+			results[i] = []*keptnv2.SLIResult{{
 				Metric:  indicatorName,
-				Value:   *points[len(points)-1][1],
-				Success: true,
+				Success: false,
+				Message: fmt.Sprintf("unknown indicator: '%s' is not defined in %s", indicatorName, sliFile),
+			}}
+			return
+		}
+
+		values := []indicatorValue{evaluateIndicator(ctx, querier, baseName, indicatorConfig, queryContext, start, end)}
+		if indicatorConfig.Baseline != "" {
+			baselineStart, baselineEnd := indicatorConfig.Baseline.Window(start, end)
+			values = append(values, evaluateIndicator(ctx, querier, baseName+sli.BaselineSuffix, indicatorConfig, queryContext, baselineStart, baselineEnd))
+		}
+
+		for _, value := range values {
+			if value.policy != "" {
+				appliedPolicies[i] = append(appliedPolicies[i], fmt.Sprintf("%s: %s", value.metric, value.policy))
+			}
+			if value.err != nil && value.policy == sli.MissingDataFail {
+				failEvaluation[i] = true
+			}
+			if value.err != nil && ctx.Err() != nil {
+				timedOut[i] = true
 			}
-			logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Debugf("SLI result from the metrics api: %v", sliResult)
-			sliResults = append(sliResults, sliResult)
-		} else {
-			logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Debugf("got 0 in the SLI result (indicates empty response from the API)")
 		}
 
+		var sliResult []*keptnv2.SLIResult
+		switch {
+		case indicatorName == baseName:
+			sliResult = append(sliResult, values[0].sliResult())
+			if len(values) > 1 {
+				sliResult = append(sliResult, values[1].sliResult(), changeSLIResult(baseName+sli.ChangeSuffix, values[0], values[1]))
+			}
+		case indicatorName == baseName+sli.BaselineSuffix:
+			sliResult = append(sliResult, values[1].sliResult())
+		default:
+			sliResult = append(sliResult, changeSLIResult(indicatorName, values[0], values[1]))
+		}
+
+		for _, r := range sliResult {
+			if r != nil {
+				logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Debugf("SLI result from the metrics api: %v", r)
+				results[i] = append(results[i], r)
+			}
+		}
+	})
+
+	errored := false
+	notEvaluated := 0
+	policies := []string{}
+	// baseline and change SLIs can be part of several results, e.g. if response_time and response_time_change are requested
+	reported := map[string]bool{}
+	for i := range indicators {
+		if failEvaluation[i] {
+			errored = true
+		}
+		if timedOut[i] {
+			notEvaluated++
+		}
+		for _, r := range results[i] {
+			if !reported[r.Metric] {
+				reported[r.Metric] = true
+				sliResults = append(sliResults, r)
+			}
+		}
+		policies = append(policies, appliedPolicies[i]...)
 	}
 
 	// Step 7 - Build get-sli.finished event data
@@ -161,10 +278,24 @@ func HandleGetSliTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevent
 		},
 	}
 
+	messages := []string{}
 	if errored {
 		getSliFinishedEventData.EventData.Status = keptnv2.StatusErrored
 		getSliFinishedEventData.EventData.Result = keptnv2.ResultFailed
+		messages = append(messages, "failing the evaluation because indicators returned no data")
+	}
+
+	if notEvaluated > 0 {
+		if !errored {
+			getSliFinishedEventData.EventData.Result = keptnv2.ResultWarning
+		}
+		messages = append(messages, fmt.Sprintf("get-sli timed out after %v, %d of %d indicators could not be evaluated", timeout, notEvaluated, len(indicators)))
+	}
+
+	if len(policies) > 0 {
+		messages = append(messages, fmt.Sprintf("applied missing_data policies (%s)", strings.Join(policies, ", ")))
 	}
+	getSliFinishedEventData.EventData.Message = strings.Join(messages, ", ")
 
 	logger.Debugf("SLI finished event: %v", *getSliFinishedEventData)
 
@@ -179,6 +310,10 @@ func HandleGetSliTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevent
 	return nil
 }
 
+// HandleConfigureMonitoringTriggeredEvent handles configure-monitoring.triggered events if the monitoring type is datadog
+// or a configured Datadog instance. It adds starter datadog/sli.yaml and slo.yaml files that are missing and creates or
+// updates a Datadog monitor per objective in the slo.yaml of the service for the stage of the event, or for all stages
+// of the shipyard if the event has no stage, and a dashboard of the service.
 func HandleConfigureMonitoringTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.ConfigureMonitoringTriggeredEventData) error {
 	var shkeptncontext string
 	_ = incomingEvent.Context.ExtensionAs("shkeptncontext", &shkeptncontext)
@@ -192,17 +327,34 @@ func HandleConfigureMonitoringTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEve
 		return err
 	}
 
+	labels := map[string]string{}
+	for key, value := range data.Labels {
+		labels[key] = value
+	}
+
 	configureMonitoringFinishedEventData := &keptnv2.ConfigureMonitoringFinishedEventData{
 		EventData: keptnv2.EventData{
 			Status:  keptnv2.StatusSucceeded,
 			Result:  keptnv2.ResultPass,
 			Project: data.Project,
-			Stage:   data.Service,
+			Stage:   data.Stage,
 			Service: data.Service,
+			Labels:  labels,
 			Message: "Finished configuring monitoring",
 		},
 	}
 
+	messages, err := configureMonitoring(ddKeptn, data, labels)
+	if err != nil {
+		logger.Errorf("failed to configure monitoring: %v", err)
+		configureMonitoringFinishedEventData.Status = keptnv2.StatusErrored
+		configureMonitoringFinishedEventData.Result = keptnv2.ResultFailed
+		messages = append(messages, err.Error())
+	}
+	if len(messages) > 0 {
+		configureMonitoringFinishedEventData.Message += ": " + strings.Join(messages, "; ")
+	}
+
 	logger.Debugf("Configure Monitoring finished event: %v", *configureMonitoringFinishedEventData)
 
 	_, err = ddKeptn.SendTaskFinishedEvent(configureMonitoringFinishedEventData, ServiceName)
@@ -215,6 +367,316 @@ func HandleConfigureMonitoringTriggeredEvent(ddKeptn *keptnv2.Keptn, incomingEve
 	return nil
 }
 
+// configureMonitoring configures the Datadog objects of the service in every stage of the event and its dashboard.
+// It returns a message per configured stage and the dashboard until the first error, links are added to labels.
+func configureMonitoring(ddKeptn *keptnv2.Keptn, data *keptnv2.ConfigureMonitoringTriggeredEventData, labels map[string]string) ([]string, error) {
+	stages := []string{data.Stage}
+	if data.Stage == "" {
+		shipyard, err := ddKeptn.GetShipyard()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the shipyard of project %s: %w", data.Project, err)
+		}
+		stages = nil
+		for _, stage := range shipyard.Spec.Stages {
+			stages = append(stages, stage.Name)
+		}
+	}
+
+	messages := []string{}
+	for _, stage := range stages {
+		target := monitoring.Target{Project: data.Project, Stage: stage, Service: data.Service}
+		message, err := configureStageMonitoring(ddKeptn, data.ConfigureMonitoring.Type, target)
+		if err != nil {
+			return messages, fmt.Errorf("%s: %w", stage, err)
+		}
+		messages = append(messages, stage+": "+message)
+	}
+
+	if !env.CreateDashboards || len(stages) == 0 {
+		return messages, nil
+	}
+	url, message, err := configureDashboard(ddKeptn, data.ConfigureMonitoring.Type, data.Project, data.Service, stages)
+	if err != nil {
+		return messages, fmt.Errorf("dashboard: %w", err)
+	}
+	labels[dashboardLabel] = url
+	return append(messages, "dashboard: "+message), nil
+}
+
+// dashboardLabel is the label of the configure-monitoring.finished event with the URL of the dashboard
+const dashboardLabel = "Datadog dashboard"
+
+// configureDashboard creates or updates the dashboard of the service with a widget per indicator of the stages,
+// it returns the URL of the dashboard
+func configureDashboard(ddKeptn *keptnv2.Keptn, provider, project, service string, stages []string) (string, string, error) {
+	configs := []*sli.Config{}
+	for _, stage := range stages {
+		sliConfig, err := sli.GetConfiguration(ddKeptn.ResourceHandler, project, stage, service, sliFile)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to read %s of stage %s: %w", sliFile, stage, err)
+		}
+		configs = append(configs, sliConfig)
+	}
+
+	data, err := monitoring.NewDashboardData(project, service, stages, monitoring.DashboardIndicators(configs...))
+	if err != nil {
+		return "", "", err
+	}
+	dashboardTemplate, err := monitoring.GetDashboardTemplate(ddKeptn.ResourceHandler, project, stages[0], service)
+	if err != nil {
+		return "", "", err
+	}
+	dashboard, err := monitoring.RenderDashboard(dashboardTemplate, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	// the dashboard shows all stages, so it is created with the credentials of the first one
+	ddCredentials, err := credentials.Lookup(context.Background(), instanceCredentialsProvider(provider), project, stages[0])
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get the Datadog credentials: %w", err)
+	}
+	redactor.AddSecrets(ddCredentials.APIKey, ddCredentials.AppKey)
+
+	saved, created, err := monitoring.SyncDashboard(ddCredentials.Context(context.Background()), datadogClient().DashboardsApi, project, service, dashboard)
+	if err != nil {
+		return "", "", err
+	}
+	url := ddCredentials.AppURL() + saved.GetUrl()
+	action := "updated"
+	if created {
+		action = "created"
+	}
+	logger.Infof("%s the dashboard of %s: %s", action, service, url)
+	return url, action + " " + url, nil
+}
+
+// configureStageMonitoring adds the missing starter resources of the target and creates or updates the monitors and
+// SLOs of its objectives
+func configureStageMonitoring(ddKeptn *keptnv2.Keptn, provider string, target monitoring.Target) (string, error) {
+	messages := []string{}
+	if env.SeedResources {
+		added, err := monitoring.SeedResources(ddKeptn.ResourceHandler, target)
+		if err != nil {
+			return "", err
+		}
+		if len(added) > 0 {
+			logger.Infof("added the starter %s to %s in stage %s", strings.Join(added, " and "), target.Service, target.Stage)
+			messages = append(messages, "added starter "+strings.Join(added, " and "))
+		}
+	}
+
+	objectives, err := monitoring.GetObjectives(ddKeptn.ResourceHandler, target)
+	if err != nil {
+		return "", err
+	}
+	if objectives == nil {
+		objectives = &keptnv1.ServiceLevelObjectives{}
+	}
+	if len(objectives.Objectives) == 0 {
+		logger.Infof("no objectives for %s in stage %s in %s", target.Service, target.Stage, monitoring.SLOFile)
+		messages = append(messages, "no objectives in "+monitoring.SLOFile)
+		// the monitors and SLOs of removed objectives are still deleted
+		if !env.CreateMonitors && !env.CreateSLOs {
+			return strings.Join(messages, ", "), nil
+		}
+	}
+
+	sliConfig, err := sli.GetConfiguration(ddKeptn.ResourceHandler, target.Project, target.Stage, target.Service, sliFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %w", sliFile, err)
+	}
+
+	ddCredentials, err := credentials.Lookup(context.Background(), instanceCredentialsProvider(provider), target.Project, target.Stage)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the Datadog credentials: %w", err)
+	}
+	redactor.AddSecrets(ddCredentials.APIKey, ddCredentials.AppKey)
+	ctx := ddCredentials.Context(context.Background())
+
+	if env.CreateMonitors {
+		monitors := []*datadog.Monitor{}
+		skipped := []monitoring.Skipped{}
+		for _, objective := range objectives.Objectives {
+			if objective == nil {
+				continue
+			}
+			indicator, ok := sliConfig.Indicators[objective.SLI]
+			if !ok {
+				skipped = append(skipped, monitoring.Skipped{SLI: objective.SLI, Reason: fmt.Errorf("not defined in %s", sliFile)})
+				continue
+			}
+			monitor, err := monitoring.BuildMonitor(target, objective, indicator, env.MonitorWindow)
+			if err != nil {
+				logger.WithFields(logger.Fields{"indicatorName": objective.SLI}).Warnf("not creating a monitor: %v", err)
+				skipped = append(skipped, monitoring.Skipped{SLI: objective.SLI, Reason: err})
+				continue
+			}
+			monitors = append(monitors, monitor)
+		}
+
+		result, err := monitoring.SyncMonitors(ctx, datadogClient().MonitorsApi, target, monitors, skipped)
+		if err != nil {
+			return "", err
+		}
+		logger.Infof("configured the monitors of %s in stage %s: %s", target.Service, target.Stage, result.Summary("monitors"))
+		messages = append(messages, result.Summary("monitors"))
+	}
+
+	if env.CreateSLOs {
+		result, err := configureSLOs(ctx, target, objectives, sliConfig)
+		if err != nil {
+			return "", err
+		}
+		logger.Infof("configured the SLOs of %s in stage %s: %s", target.Service, target.Stage, result.Summary("SLOs"))
+		messages = append(messages, result.Summary("SLOs"))
+	}
+	return strings.Join(messages, ", "), nil
+}
+
+// configureSLOs mirrors the objectives of the target as Datadog SLOs, monitor-based if the objective has a monitor
+// and metric-based otherwise, and deletes the SLOs of objectives that are gone
+func configureSLOs(ctx context.Context, target monitoring.Target, objectives *keptnv1.ServiceLevelObjectives, sliConfig *sli.Config) (monitoring.Result, error) {
+	monitorIDs, err := monitoring.MonitorIDs(ctx, datadogClient().MonitorsApi, target)
+	if err != nil {
+		return monitoring.Result{}, err
+	}
+
+	slos := []*datadog.ServiceLevelObjective{}
+	skipped := []monitoring.Skipped{}
+	options := monitoring.SLOOptions{Timeframe: datadog.SLOTimeframe(env.SLOTimeframe), MonitorTarget: env.SLOMonitorTarget}
+	for _, objective := range objectives.Objectives {
+		if objective == nil {
+			continue
+		}
+		indicator, ok := sliConfig.Indicators[objective.SLI]
+		if !ok {
+			skipped = append(skipped, monitoring.Skipped{SLI: objective.SLI, Reason: fmt.Errorf("not defined in %s", sliFile)})
+			continue
+		}
+		slo, err := monitoring.BuildSLO(target, objective, indicator, monitorIDs[sli.TagEscape(objective.SLI)], options)
+		if err != nil {
+			logger.WithFields(logger.Fields{"indicatorName": objective.SLI}).Warnf("not creating an SLO: %v", err)
+			skipped = append(skipped, monitoring.Skipped{SLI: objective.SLI, Reason: err})
+			continue
+		}
+		slos = append(slos, slo)
+	}
+
+	return monitoring.SyncSLOs(ctx, datadogClient().ServiceLevelObjectivesApi, target, slos, skipped)
+}
+
+// HandleTaskFinishedEvent posts a deployment.finished, release.finished or evaluation.finished event to the Datadog
+// event stream of the instance that is the SLI provider of the project, with the credentials of its project and stage.
+// Events of projects that don't use Datadog or have no Datadog credentials are ignored. Details are added to the text
+// of the Datadog event.
+func HandleTaskFinishedEvent(incomingEvent cloudevents.Event, task string, data keptnv2.EventData, details []string) error {
+	var shkeptncontext string
+	_ = incomingEvent.Context.ExtensionAs("shkeptncontext", &shkeptncontext)
+	configureLogger(incomingEvent.Context.GetID(), shkeptncontext)
+
+	logger.Infof("Handling %s Event: %s", incomingEvent.Type(), incomingEvent.Context.GetID())
+
+	event := monitoring.BuildEvent(monitoring.LifecycleEvent{
+		Target:       monitoring.Target{Project: data.Project, Stage: data.Stage, Service: data.Service},
+		Task:         task,
+		KeptnContext: shkeptncontext,
+		Version:      data.Labels[versionLabel],
+		Status:       string(data.Status),
+		Result:       string(data.Result),
+		Message:      data.Message,
+		Details:      details,
+	})
+
+	provider, ok, err := projectProvider(context.Background(), data.Project)
+	if err != nil {
+		logger.Errorf("unable to get the SLI provider of project %s: %v", data.Project, err)
+		return err
+	}
+	if !ok || !servesProvider(provider) {
+		logger.Infof("Not posting the event to Datadog as project %s uses the SLI provider '%s'", data.Project, provider)
+		return nil
+	}
+
+	ddCredentials, err := credentials.Lookup(context.Background(), instanceCredentialsProvider(provider), data.Project, data.Stage)
+	if errors.Is(err, credentials.ErrNotFound) {
+		logger.Infof("Not posting the event to Datadog: %v", err)
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("unable to get the Datadog credentials: %v", err)
+		return err
+	}
+	redactor.AddSecrets(ddCredentials.APIKey, ddCredentials.AppKey)
+
+	if err := monitoring.PostEvent(ddCredentials.Context(context.Background()), datadogClient().EventsApi, event); err != nil {
+		logger.Errorf("failed to post the Datadog event: %v", err)
+		return err
+	}
+	logger.Infof("posted the Datadog event '%s'", event.Title)
+	return nil
+}
+
+// versionLabel is the label of Keptn events with the version of the service, e.g. set by keptn trigger delivery --labels
+const versionLabel = "version"
+
+// deploymentDetails describes the deployment of a deployment.finished event
+func deploymentDetails(deployment keptnv2.DeploymentFinishedData) []string {
+	details := []string{}
+	if deployment.DeploymentStrategy != "" {
+		details = append(details, "Deployment strategy: "+deployment.DeploymentStrategy)
+	}
+	uris := deployment.DeploymentURIsPublic
+	if len(uris) == 0 {
+		uris = deployment.DeploymentURIsLocal
+	}
+	if len(uris) > 0 {
+		details = append(details, "Deployment URIs: "+strings.Join(uris, ", "))
+	}
+	return details
+}
+
+// evaluationDetails describes the score and the indicator results of an evaluation.finished event
+func evaluationDetails(evaluation keptnv2.EvaluationDetails) []string {
+	details := []string{fmt.Sprintf("Score: %s", strconv.FormatFloat(evaluation.Score, 'f', -1, 64))}
+	for _, result := range evaluation.IndicatorResults {
+		if result == nil || result.Value == nil {
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s: %s (%s)", result.Value.Metric, strconv.FormatFloat(result.Value.Value, 'f', -1, 64), result.Status))
+	}
+	return details
+}
+
+// runConcurrently calls fn for every index in [0, n) using a pool of at most limit workers
+// and returns once all calls have finished
+func runConcurrently(n, limit int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > n {
+		limit = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(limit)
+	for w := 0; w < limit; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
 func configureLogger(eventID, keptnContext string) {
 	logger.SetFormatter(&utils.Formatter{
 		Fields: logger.Fields{
@@ -223,6 +685,7 @@ func configureLogger(eventID, keptnContext string) {
 			"keptnContext": keptnContext,
 		},
 		BuiltinFormatter: &logger.TextFormatter{},
+		Redactor:         redactor,
 	})
 
 	if os.Getenv(envVarLogLevel) != "" {
@@ -235,22 +698,220 @@ func configureLogger(eventID, keptnContext string) {
 	}
 }
 
-func replaceQueryParameters(data *keptnv2.GetSLITriggeredEventData, query string, start, end time.Time) string {
-	query = strings.Replace(query, "$PROJECT", data.Project, -1)
-	query = strings.Replace(query, "$STAGE", data.Stage, -1)
-	query = strings.Replace(query, "$SERVICE", data.Service, -1)
-	query = strings.Replace(query, "$project", data.Project, -1)
-	query = strings.Replace(query, "$stage", data.Stage, -1)
-	query = strings.Replace(query, "$service", data.Service, -1)
-	durationString := strconv.FormatInt(getDurationInSeconds(start, end), 10)
-	query = strings.Replace(query, "$DURATION", durationString, -1)
-	return query
+// errInvalidQuery is returned for indicators whose queries can't be rendered
+var errInvalidQuery = errors.New("invalid query")
+
+// failedSLIResult builds the result of an indicator that could not be evaluated with the cause as message
+func failedSLIResult(indicatorName string, err error) *keptnv2.SLIResult {
+	var apiErr *metrics.APIError
+	var message string
+	switch {
+	case errors.Is(err, sli.ErrNoDataPoints):
+		message = fmt.Sprintf("empty response: Datadog returned no data for the query (%v)", err)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		message = fmt.Sprintf("timeout: the query did not finish in time (%v)", err)
+	case errors.Is(err, errInvalidQuery):
+		message = err.Error()
+	case errors.As(err, &apiErr) && apiErr.IsAuthError():
+		message = fmt.Sprintf("auth failure: Datadog rejected the API or application key (%v)", err)
+	default:
+		message = fmt.Sprintf("API error: %v", err)
+	}
+
+	return &keptnv2.SLIResult{
+		Metric:  indicatorName,
+		Success: false,
+		Message: message,
+	}
+}
+
+// credentialsProvider resolves the Datadog credentials of a project and stage,
+// the environment is used if it is nil
+var credentialsProvider credentials.Provider
+
+// apiClient is the Datadog API client shared by all events, a client with default settings is used if it is nil
+var apiClient *datadog.APIClient
+
+// metricsBackend queries the indicators, the Datadog metrics API of apiClient is used if it is nil
+var metricsBackend metrics.Backend
+
+// datadogClient returns the shared Datadog API client or a client with default settings if there is none
+func datadogClient() *datadog.APIClient {
+	if apiClient == nil {
+		return datadog.NewAPIClient(datadog.NewConfiguration())
+	}
+	return apiClient
+}
+
+// rateLimits are shared by all queries to the same organization, as Datadog limits the requests per organization
+var rateLimits = &metrics.RateLimits{}
+
+// indicatorQuerier holds everything needed to query indicator values from the metrics backend
+type indicatorQuerier struct {
+	backend metrics.Backend
+	// poller repeats a query until Datadog reflects the data of the evaluation window
+	poller metrics.Poller
+	// retrier repeats a query that failed because of a transient error
+	retrier metrics.Retrier
+}
+
+// indicatorValue is the outcome of evaluating an indicator within one time window
+type indicatorValue struct {
+	metric string
+	value  float64
+	// policy is the missing_data policy applied because the query returned no data
+	policy sli.MissingDataPolicy
+	err    error
+}
+
+// sliResult returns the SLI result for the value, or nil if the indicator is left out because of missing_data: skip
+func (v indicatorValue) sliResult() *keptnv2.SLIResult {
+	if v.err != nil {
+		if v.policy == sli.MissingDataSkip {
+			return nil
+		}
+		return failedSLIResult(v.metric, v.err)
+	}
+
+	sliResult := &keptnv2.SLIResult{
+		Metric:  v.metric,
+		Value:   v.value,
+		Success: true,
+	}
+	if v.policy != "" {
+		sliResult.Message = fmt.Sprintf("no data, reported %v as defined by missing_data policy '%s'", v.value, v.policy)
+	}
+	return sliResult
+}
+
+// changeSLIResult returns the SLI result with the relative change of current compared to baseline in percent
+func changeSLIResult(metric string, current, baseline indicatorValue) *keptnv2.SLIResult {
+	for _, v := range []indicatorValue{current, baseline} {
+		if v.err != nil {
+			if v.policy == sli.MissingDataSkip {
+				return nil
+			}
+			return failedSLIResult(metric, v.err)
+		}
+	}
+
+	change, err := sli.RelativeChange(current.value, baseline.value)
+	if err != nil {
+		return &keptnv2.SLIResult{
+			Metric:  metric,
+			Success: false,
+			Message: err.Error(),
+		}
+	}
+	return &keptnv2.SLIResult{
+		Metric:  metric,
+		Value:   change,
+		Success: true,
+	}
+}
+
+// evaluateIndicator renders the queries of the indicator for the window between start and end, queries its value
+// and applies the missing_data policy if the query returns no data
+func evaluateIndicator(ctx context.Context, querier indicatorQuerier, metric string, indicatorConfig sli.Indicator, queryContext sli.QueryContext, start, end time.Time) indicatorValue {
+	log := logger.WithFields(logger.Fields{"indicatorName": metric})
+
+	indicator, err := indicatorConfig.Render(queryContext.WithWindow(start, end))
+	if err != nil {
+		log.Errorf("unable to render the indicator queries: %v", err)
+		return indicatorValue{metric: metric, err: fmt.Errorf("%w: %v", errInvalidQuery, err)}
+	}
+
+	value, err := getIndicatorValue(ctx, querier, metric, indicator, start, end)
+	if !errors.Is(err, sli.ErrNoDataPoints) {
+		return indicatorValue{metric: metric, value: indicator.UnitConversion.Apply(value), err: err}
+	}
+
+	policy := indicator.MissingData
+	if policy == "" {
+		policy = env.MissingDataPolicy
+	}
+
+	if fixedValue, ok := policy.Value(); ok {
+		log.Debugf("query returned no data, reporting %v as defined by missing_data policy '%s': %v", fixedValue, policy, err)
+		return indicatorValue{metric: metric, value: indicator.UnitConversion.Apply(fixedValue), policy: policy}
+	}
+
+	switch policy {
+	case sli.MissingDataSkip:
+		log.Debugf("query returned no data, leaving the indicator out as defined by missing_data policy: %v", err)
+	case sli.MissingDataFail:
+		log.Errorf("query returned no data, failing as defined by missing_data policy: %v", err)
+	}
+	return indicatorValue{metric: metric, policy: policy, err: err}
+}
+
+// getIndicatorValue computes the value of an indicator within the indicator timeout
+// The fallback query of the indicator is used if the indicator query fails or returns no data
+func getIndicatorValue(ctx context.Context, querier indicatorQuerier, indicatorName string, indicator sli.Indicator, start, end time.Time) (float64, error) {
+	if indicator.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, indicator.Timeout)
+		defer cancel()
+
+		if indicator.Timeout < querier.poller.MaxWait {
+			querier.poller.MaxWait = indicator.Timeout
+		}
+	}
+
+	querier.retrier.OnRetry = func(attempt int, err error, delay time.Duration) {
+		logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Warnf("attempt %d of %d failed, retrying in %v: %v", attempt, querier.retrier.MaxAttempts, delay, err)
+	}
+
+	value, err := queryIndicatorValue(ctx, querier, indicatorName, indicator, start, end)
+	if err != nil && indicator.FallbackQuery != "" {
+		logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Warnf("using the fallback query because the indicator query returned: %v", err)
+		return queryIndicatorValue(ctx, querier, indicatorName, indicator.Fallback(), start, end)
+	}
+	return value, err
 }
 
-func getDurationInSeconds(start, end time.Time) int64 {
+// queryIndicatorValue waits for the data of the indicator query to be ready and reduces the returned series to a single value
+func queryIndicatorValue(ctx context.Context, querier indicatorQuerier, indicatorName string, indicator sli.Indicator, start, end time.Time) (float64, error) {
+	var r *http.Response
+	series, ready, err := querier.poller.WaitForData(ctx, end, func() (series []sli.Series, err error) {
+		err = querier.retrier.Do(ctx, func(attempt int) (*http.Response, error) {
+			logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Debugf("querying datadog, attempt %d: %v, queries: %v, formula: %v, from: %v, to: %v",
+				attempt, indicator.Query, indicator.Queries, indicator.Formula, start.Unix(), end.Unix())
+			series, r, err = querier.backend.Query(ctx, indicator, start, end)
+			return r, err
+		})
+		return series, err
+	})
+	// if the timeout is reached while waiting for the data, the last response is used like data that didn't settle
+	timedOut := err != nil && series != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		ready, err = false, nil
+	}
+	if err != nil {
+		fields := logger.Fields{"indicatorName": indicatorName}
+		var apiErr *metrics.APIError
+		if errors.As(err, &apiErr) {
+			fields["statusCode"] = apiErr.StatusCode
+		}
+		logger.WithFields(fields).Errorf("error getting value for the indicator: %v", err)
+		return 0, err
+	}
+
+	logger.Debugf("series from the metrics api: %v", series)
 
-	seconds := end.Sub(start).Seconds()
-	return int64(math.Ceil(seconds))
+	if selected := indicator.SelectSeries(series); len(selected) > 1 && indicator.SeriesAggregation == "" {
+		logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Warnf("query returned %d series and neither scope nor series_aggregation narrows them down, using the first series with data", len(selected))
+	}
+
+	value, err := indicator.Value(series)
+	switch {
+	case err != nil || ready:
+	case timedOut:
+		logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Warnf("data was not ready when the timeout was reached, using the last response: %v", value)
+	default:
+		logger.WithFields(logger.Fields{"indicatorName": indicatorName}).Warnf("data did not settle within %v, using the last response: %v", querier.poller.MaxWait, value)
+	}
+	return value, err
 }
 
 func parseUnixTimestamp(timestamp string) (time.Time, error) {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/keptn-sandbox/datadog-service/pkg/credentials"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// datadogProvider is the sliProvider and monitoring type of the default Datadog connection
+const datadogProvider = "datadog"
+
+// parseProvider returns the name of the Datadog instance selected by an sliProvider or monitoring type:
+// datadog selects the default connection (""), datadog-<instance> and datadog/<instance> a named instance.
+// ok is false if the provider is not Datadog.
+func parseProvider(provider string) (instance string, ok bool) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == datadogProvider {
+		return "", true
+	}
+
+	for _, separator := range []string{"-", "/"} {
+		if instance := strings.TrimPrefix(provider, datadogProvider+separator); instance != provider && instance != "" {
+			return instance, true
+		}
+	}
+	return "", false
+}
+
+// servesProvider checks if the provider selects the default connection or one of the configured Datadog instances
+func servesProvider(provider string) bool {
+	instance, ok := parseProvider(provider)
+	if !ok {
+		return false
+	}
+	if instance == "" {
+		return true
+	}
+
+	for _, configured := range env.Instances {
+		if strings.EqualFold(strings.TrimSpace(configured), instance) {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceCredentialsProvider returns the credentials provider of the Datadog instance selected by provider:
+// named instances use the <INSTANCE>_DD_API_KEY, <INSTANCE>_DD_APP_KEY and <INSTANCE>_DD_SITE env vars,
+// the default connection the per-project credentials
+func instanceCredentialsProvider(provider string) credentials.Provider {
+	instance, _ := parseProvider(provider)
+	if instance == "" {
+		return credentialsProvider
+	}
+	return credentials.Environment{Prefix: credentials.InstancePrefix(instance)}
+}
+
+// lighthouseConfigName is the ConfigMap with the default SLI provider of all projects, lighthouse-config-<project>
+// overrides it for a project. They are set by keptn configure monitoring and read by the lighthouse-service.
+const lighthouseConfigName = "lighthouse-config"
+
+// lighthouseConfigs reads the SLI providers of the projects, it is nil if no Kubernetes cluster is available
+var lighthouseConfigs typedcorev1.ConfigMapsGetter
+
+// projectProvider returns the SLI provider of the project from its lighthouse ConfigMap, ok is false if the project
+// has none. Without a Kubernetes cluster, every project is assumed to use the default Datadog connection.
+func projectProvider(ctx context.Context, project string) (provider string, ok bool, err error) {
+	if lighthouseConfigs == nil {
+		return datadogProvider, true, nil
+	}
+
+	for _, name := range []string{lighthouseConfigName + "-" + project, lighthouseConfigName} {
+		configMap, err := lighthouseConfigs.ConfigMaps(env.PodNamespace).Get(ctx, name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("unable to read ConfigMap '%s': %w", name, err)
+		}
+		if provider := configMap.Data["sli-provider"]; provider != "" {
+			return provider, true, nil
+		}
+	}
+	return "", false, nil
+}
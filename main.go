@@ -5,19 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
 	"github.com/kelseyhightower/envconfig"
 
+	"github.com/keptn-sandbox/datadog-service/pkg/apiclient"
+	"github.com/keptn-sandbox/datadog-service/pkg/credentials"
+	"github.com/keptn-sandbox/datadog-service/pkg/metrics"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
 	"github.com/keptn-sandbox/datadog-service/pkg/utils"
 	keptnv1 "github.com/keptn/go-utils/pkg/lib"
 	"github.com/keptn/go-utils/pkg/lib/keptn"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
 	logger "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 var keptnOptions = keptn.KeptnOpts{}
 
+// redactor masks the Datadog keys in all log messages
+var redactor = &utils.Redactor{}
+
+// env holds the service configuration read from environment variables on startup
+var env envConfig
+
 const (
 	envVarLogLevel = "LOG_LEVEL"
 )
@@ -31,6 +47,63 @@ type envConfig struct {
 	Env string `envconfig:"ENV" default:"local"`
 	// URL of the Keptn configuration service (this is where we can fetch files from the config repo)
 	ConfigurationServiceUrl string `envconfig:"CONFIGURATION_SERVICE" default:""`
+	// Maximum number of indicator queries sent to Datadog in parallel while handling a get-sli event
+	MaxConcurrentQueries int `envconfig:"MAX_CONCURRENT_QUERIES" default:"5"`
+	// Maximum time to wait for Datadog to reflect the data of the evaluation window before the last response is used
+	MaxDataWaitInSeconds int `envconfig:"MAX_DATA_WAIT_IN_SECONDS" default:"120"`
+	// Time to wait between two queries while waiting for Datadog to reflect the data of the evaluation window, at least 1
+	DataPollIntervalInSeconds int `envconfig:"DATA_POLL_INTERVAL_IN_SECONDS" default:"10"`
+	// Maximum time spent on a get-sli event, can be overridden per project by the timeout in datadog/sli.yaml
+	GetSliTimeoutInSeconds int `envconfig:"GET_SLI_TIMEOUT_IN_SECONDS" default:"300"`
+	// Maximum number of attempts for a Datadog query that fails because of a transient error
+	MaxQueryAttempts int `envconfig:"MAX_QUERY_ATTEMPTS" default:"4"`
+	// Delay before the first retry of a failed query, it doubles with every further retry
+	RetryBaseDelayInSeconds int `envconfig:"RETRY_BASE_DELAY_IN_SECONDS" default:"1"`
+	// Maximum delay between two attempts of a failed query
+	RetryMaxDelayInSeconds int `envconfig:"RETRY_MAX_DELAY_IN_SECONDS" default:"30"`
+	// Read Datadog credentials from Keptn secrets named datadog-credentials-<project>[-<stage>]
+	CredentialsFromSecrets bool `envconfig:"CREDENTIALS_FROM_SECRETS" default:"true"`
+	// Namespace of the Keptn secrets with Datadog credentials
+	PodNamespace string `envconfig:"POD_NAMESPACE" default:"keptn"`
+	// Directory with Datadog credentials in sub directories named <project>[-<stage>]
+	CredentialsDir string `envconfig:"CREDENTIALS_DIR" default:""`
+	// Named Datadog instances selected by the sliProvider or monitoring type datadog-<instance> or datadog/<instance>,
+	// their credentials are read from the <INSTANCE>_DD_API_KEY, <INSTANCE>_DD_APP_KEY and <INSTANCE>_DD_SITE env vars
+	Instances []string `envconfig:"DATADOG_INSTANCES" default:""`
+	// Proxy for all Datadog requests, the HTTPS_PROXY, HTTP_PROXY and NO_PROXY env vars are used if it is empty
+	DatadogProxy string `envconfig:"DATADOG_PROXY" default:""`
+	// PEM file with certificates that are trusted for Datadog requests in addition to the system certificates
+	DatadogCABundle string `envconfig:"DATADOG_CA_BUNDLE" default:""`
+	// Maximum time for a single Datadog request, 0 disables the timeout
+	DatadogRequestTimeoutInSeconds int `envconfig:"DATADOG_REQUEST_TIMEOUT_IN_SECONDS" default:"30"`
+	// Interval of the TCP keep-alive probes of the connections to Datadog
+	DatadogKeepAliveInSeconds int `envconfig:"DATADOG_KEEP_ALIVE_IN_SECONDS" default:"30"`
+	// Number of idle connections to Datadog that are kept open for later requests
+	DatadogMaxIdleConnections int `envconfig:"DATADOG_MAX_IDLE_CONNECTIONS" default:"10"`
+	// Time an idle connection to Datadog is kept open
+	DatadogIdleConnTimeoutInSeconds int `envconfig:"DATADOG_IDLE_CONN_TIMEOUT_IN_SECONDS" default:"90"`
+	// Log method, URL, status and duration of every Datadog request on debug level
+	DatadogDebugRequests bool `envconfig:"DATADOG_DEBUG_REQUESTS" default:"false"`
+	// Time query results of windows that Datadog fully reflects already are cached, 0 disables the cache
+	QueryCacheTTLInSeconds int `envconfig:"QUERY_CACHE_TTL_IN_SECONDS" default:"0"`
+	// Add starter datadog/sli.yaml and slo.yaml files to services that have none when handling configure-monitoring events
+	SeedResources bool `envconfig:"SEED_RESOURCES" default:"true"`
+	// Create or update a Datadog monitor per objective in slo.yaml when handling configure-monitoring events
+	CreateMonitors bool `envconfig:"CREATE_MONITORS" default:"true"`
+	// Time window the queries of the monitors are evaluated over, e.g. last_5m or last_1h
+	MonitorWindow string `envconfig:"MONITOR_WINDOW" default:"last_5m"`
+	// Mirror the objectives in slo.yaml as Datadog SLOs when handling configure-monitoring events
+	CreateSLOs bool `envconfig:"CREATE_SLOS" default:"false"`
+	// Rolling time window of the Datadog SLOs, one of 7d, 30d and 90d
+	SLOTimeframe string `envconfig:"SLO_TIMEFRAME" default:"30d"`
+	// Percentage of time the monitor of a monitor-based SLO must not alert
+	SLOMonitorTarget float64 `envconfig:"SLO_MONITOR_TARGET" default:"99"`
+	// Create or update a Datadog dashboard per service when handling configure-monitoring events
+	CreateDashboards bool `envconfig:"CREATE_DASHBOARDS" default:"true"`
+	// Post deployment.finished, release.finished and evaluation.finished events to the Datadog event stream
+	SendEvents bool `envconfig:"SEND_EVENTS" default:"true"`
+	// Policy for indicators whose query returns no data and that don't define missing_data themselves
+	MissingDataPolicy sli.MissingDataPolicy `envconfig:"MISSING_DATA_POLICY" default:""`
 }
 
 // ServiceName specifies the current services name (e.g., used as source when sending CloudEvents)
@@ -128,6 +201,16 @@ func processKeptnCloudEvent(ctx context.Context, event cloudevents.Event) error
 
 		eventData := &keptnv2.ConfigureMonitoringTriggeredEventData{}
 		parseKeptnCloudEventPayload(event, eventData)
+		if eventData.ConfigureMonitoring.Type == "" {
+			// the monitoring.configure event of the Keptn CLI defines the type on the top level
+			legacyData := &keptnv1.ConfigureMonitoringEventData{}
+			parseKeptnCloudEventPayload(event, legacyData)
+			eventData.ConfigureMonitoring.Type = legacyData.Type
+		}
+		if !servesProvider(eventData.ConfigureMonitoring.Type) {
+			logger.Infof("Not handling configure-monitoring event as it is meant for %s", eventData.ConfigureMonitoring.Type)
+			return nil
+		}
 		event.SetType(keptnv2.GetTriggeredEventType(keptnv2.ConfigureMonitoringTaskName))
 
 		return HandleConfigureMonitoringTriggeredEvent(ddKeptn, event, eventData)
@@ -139,9 +222,42 @@ func processKeptnCloudEvent(ctx context.Context, event cloudevents.Event) error
 
 		eventData := &keptnv2.GetSLITriggeredEventData{}
 		parseKeptnCloudEventPayload(event, eventData)
+		if !servesProvider(eventData.GetSLI.SLIProvider) {
+			logger.Infof("Not handling get-sli event as it is meant for %s", eventData.GetSLI.SLIProvider)
+			return nil
+		}
 
 		return HandleGetSliTriggeredEvent(ddKeptn, event, eventData)
 
+	// -------------------------------------------------------
+	// sh.keptn.event.deployment.finished, release.finished and evaluation.finished (posted to the Datadog event stream)
+	case keptnv2.GetFinishedEventType(keptnv2.DeploymentTaskName): // sh.keptn.event.deployment.finished
+		if !env.SendEvents {
+			return nil
+		}
+		eventData := &keptnv2.DeploymentFinishedEventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleTaskFinishedEvent(event, keptnv2.DeploymentTaskName, eventData.EventData, deploymentDetails(eventData.Deployment))
+
+	case keptnv2.GetFinishedEventType(keptnv2.ReleaseTaskName): // sh.keptn.event.release.finished
+		if !env.SendEvents {
+			return nil
+		}
+		eventData := &keptnv2.ReleaseFinishedEventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleTaskFinishedEvent(event, keptnv2.ReleaseTaskName, eventData.EventData, nil)
+
+	case keptnv2.GetFinishedEventType(keptnv2.EvaluationTaskName): // sh.keptn.event.evaluation.finished
+		if !env.SendEvents {
+			return nil
+		}
+		eventData := &keptnv2.EvaluationFinishedEventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleTaskFinishedEvent(event, keptnv2.EvaluationTaskName, eventData.EventData, evaluationDetails(eventData.Evaluation))
+
 	}
 	// Unknown Event -> Throw Error!
 	errorMsg := fmt.Sprintf("Unhandled Keptn Cloud Event: %s", event.Type())
@@ -158,32 +274,103 @@ func processKeptnCloudEvent(ctx context.Context, event cloudevents.Event) error
  * env=runlocal   -> will fetch resources from local drive instead of configuration service
  */
 func main() {
-	logger.SetFormatter(&utils.Formatter{
-		Fields: logger.Fields{
-			"service":      "datadog-service",
-			"eventId":      "",
-			"keptnContext": "",
-		},
-		BuiltinFormatter: &logger.TextFormatter{},
-	})
-
-	if os.Getenv(envVarLogLevel) != "" {
-		logLevel, err := logger.ParseLevel(os.Getenv(envVarLogLevel))
-		if err != nil {
-			logger.WithError(err).Error("could not parse log level provided by 'LOG_LEVEL' env var")
-		} else {
-			logger.SetLevel(logLevel)
-		}
-	}
+	configureLogger("", "")
 
-	var env envConfig
 	if err := envconfig.Process("", &env); err != nil {
 		logger.Fatalf("Failed to process env var: %s", err)
 	}
 
+	if env.DataPollIntervalInSeconds < 1 {
+		logger.Fatalf("Invalid DATA_POLL_INTERVAL_IN_SECONDS: %d, it must be at least 1", env.DataPollIntervalInSeconds)
+	}
+
+	if err := env.MissingDataPolicy.Validate(); err != nil {
+		logger.Fatalf("Invalid MISSING_DATA_POLICY: %s", err)
+	}
+
+	redactor.AddSecrets(os.Getenv(credentials.APIKeyName), os.Getenv(credentials.AppKeyName))
+	for _, instance := range env.Instances {
+		prefix := credentials.InstancePrefix(strings.TrimSpace(instance))
+		redactor.AddSecrets(os.Getenv(prefix+credentials.APIKeyName), os.Getenv(prefix+credentials.AppKeyName))
+	}
+
+	credentialsProvider = newCredentialsProvider(env)
+	if clientset, err := kubernetesClient(); err != nil {
+		logger.Warnf("Not reading the SLI providers of the projects, all finished events are posted to Datadog: %v", err)
+	} else {
+		lighthouseConfigs = clientset.CoreV1()
+	}
+
+	client, err := newAPIClient(env)
+	if err != nil {
+		logger.Fatalf("Failed to create the Datadog API client: %s", err)
+	}
+	apiClient = client
+	metricsBackend = newMetricsBackend(env, client)
+
 	os.Exit(_main(os.Args[1:], env))
 }
 
+// newCredentialsProvider looks up Datadog credentials in Keptn secrets, the credentials directory and the environment
+func newCredentialsProvider(env envConfig) credentials.Provider {
+	chain := credentials.Chain{}
+
+	if env.CredentialsFromSecrets {
+		if clientset, err := kubernetesClient(); err != nil {
+			logger.Warnf("Not reading Datadog credentials from Keptn secrets: %v", err)
+		} else {
+			chain = append(chain, credentials.Secrets{Client: clientset.CoreV1(), Namespace: env.PodNamespace})
+		}
+	}
+
+	if env.CredentialsDir != "" {
+		chain = append(chain, credentials.Directory{Path: env.CredentialsDir})
+	}
+
+	return append(chain, credentials.Environment{})
+}
+
+// kubernetesClient connects to the Kubernetes cluster the service runs in
+func kubernetesClient() (*kubernetes.Clientset, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("no Kubernetes cluster available: %w", err)
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// newAPIClient creates the Datadog API client shared by all events
+func newAPIClient(env envConfig) (*datadog.APIClient, error) {
+	options := apiclient.Options{
+		ProxyURL:            env.DatadogProxy,
+		CABundle:            env.DatadogCABundle,
+		Timeout:             time.Second * time.Duration(env.DatadogRequestTimeoutInSeconds),
+		KeepAlive:           time.Second * time.Duration(env.DatadogKeepAliveInSeconds),
+		MaxIdleConnsPerHost: env.DatadogMaxIdleConnections,
+		IdleConnTimeout:     time.Second * time.Duration(env.DatadogIdleConnTimeoutInSeconds),
+	}
+	if env.DatadogDebugRequests {
+		options.Trace = logger.Debugf
+	}
+	return apiclient.New(options)
+}
+
+// newMetricsBackend queries the Datadog metrics API with client, cached if QUERY_CACHE_TTL_IN_SECONDS is set
+func newMetricsBackend(env envConfig, client *datadog.APIClient) metrics.Backend {
+	backend := metrics.Datadog{Client: client}
+	if env.QueryCacheTTLInSeconds <= 0 {
+		return backend
+	}
+
+	return &metrics.Cache{
+		Backend: backend,
+		Clock:   metrics.RealClock,
+		TTL:     time.Second * time.Duration(env.QueryCacheTTLInSeconds),
+		// data of windows that ended less than MAX_DATA_WAIT_IN_SECONDS ago may still change
+		Settled: time.Second * time.Duration(env.MaxDataWaitInSeconds),
+	}
+}
+
 /**
  * Opens up a listener on localhost:port/path and passes incoming requets to gotEvent
  */
@@ -0,0 +1,122 @@
+package apiclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+)
+
+// Options configure the HTTP client that sends all requests to the Datadog API
+type Options struct {
+	// ProxyURL is the proxy for all Datadog requests, the HTTPS_PROXY, HTTP_PROXY and NO_PROXY env vars are used if it is empty
+	ProxyURL string
+	// CABundle is the path of a PEM file with certificates that are trusted in addition to the system certificates
+	CABundle string
+	// Timeout limits a single request including reading the response body, 0 means no timeout
+	Timeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes of open connections
+	KeepAlive time.Duration
+	// MaxIdleConnsPerHost is the number of idle connections kept open to the Datadog API
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is the time an idle connection is kept open
+	IdleConnTimeout time.Duration
+	// Trace is called with a summary of every request if it is not nil
+	Trace func(format string, args ...interface{})
+}
+
+// NewHTTPClient creates an HTTP client with the proxy, TLS, timeout and keep-alive settings of o
+func NewHTTPClient(o Options) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if o.ProxyURL != "" {
+		proxyURL, err := url.Parse(o.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL '%s'", o.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.CABundle != "" {
+		pool, err := loadCABundle(o.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: o.KeepAlive,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          o.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if o.Trace != nil {
+		transport = tracingTransport{next: transport, trace: o.Trace}
+	}
+
+	return &http.Client{Transport: transport, Timeout: o.Timeout}, nil
+}
+
+// New creates a Datadog API client that sends its requests with an HTTP client configured by o.
+// The client is safe for concurrent use and meant to be shared by all events, the credentials and
+// the site are passed with the context of each request.
+func New(o Options) (*datadog.APIClient, error) {
+	httpClient, err := NewHTTPClient(o)
+	if err != nil {
+		return nil, err
+	}
+
+	configuration := datadog.NewConfiguration()
+	configuration.HTTPClient = httpClient
+	return datadog.NewAPIClient(configuration), nil
+}
+
+// loadCABundle returns the system certificates extended by the certificates in the PEM file at path
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle '%s'", path)
+	}
+	return pool, nil
+}
+
+// tracingTransport reports the method, URL, status and duration of every request,
+// headers are left out as they contain the Datadog API and application keys
+type tracingTransport struct {
+	next  http.RoundTripper
+	trace func(format string, args ...interface{})
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.trace("datadog request %s %s failed after %v: %v", req.Method, req.URL.Redacted(), time.Since(started), err)
+		return resp, err
+	}
+	t.trace("datadog request %s %s returned %s in %v", req.Method, req.URL.Redacted(), resp.Status, time.Since(started))
+	return resp, nil
+}
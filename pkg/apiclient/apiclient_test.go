@@ -0,0 +1,114 @@
+package apiclient
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCABundle(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600))
+	return path
+}
+
+func TestNewHTTPClientTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(Options{})
+	require.NoError(t, err)
+	_, err = client.Get(server.URL)
+	assert.Error(t, err, "the certificate of the test server is not trusted by default")
+
+	client, err = NewHTTPClient(Options{CABundle: writeCABundle(t, server)})
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewHTTPClientInvalidCABundle(t *testing.T) {
+	_, err := NewHTTPClient(Options{CABundle: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, ioutil.WriteFile(path, []byte("no certificates"), 0600))
+	_, err = NewHTTPClient(Options{CABundle: path})
+	assert.EqualError(t, err, fmt.Sprintf("no certificates found in CA bundle '%s'", path))
+}
+
+func TestNewHTTPClientUsesProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(Options{ProxyURL: proxy.URL})
+	require.NoError(t, err)
+	resp, err := client.Get("http://api.datadoghq.example/api/v1/query")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "http://api.datadoghq.example/api/v1/query", proxied)
+}
+
+func TestNewHTTPClientInvalidProxy(t *testing.T) {
+	_, err := NewHTTPClient(Options{ProxyURL: "not a url"})
+	assert.EqualError(t, err, "invalid proxy URL 'not a url'")
+}
+
+func TestNewHTTPClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(Options{Timeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+	_, err = client.Get(server.URL)
+	assert.Error(t, err)
+}
+
+func TestNewHTTPClientTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	var traces []string
+	client, err := NewHTTPClient(Options{Trace: func(format string, args ...interface{}) {
+		traces = append(traces, fmt.Sprintf(format, args...))
+	}})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/query?query=avg:system.cpu.user{*}", nil)
+	require.NoError(t, err)
+	req.Header.Set("DD-API-KEY", "secret-api-key")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Len(t, traces, 1)
+	assert.Contains(t, traces[0], "GET "+server.URL+"/api/v1/query?query=avg:system.cpu.user{*} returned 429 Too Many Requests")
+	assert.NotContains(t, traces[0], "secret-api-key")
+}
+
+func TestNew(t *testing.T) {
+	client, err := New(Options{Timeout: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, client.GetConfig().HTTPClient.Timeout)
+
+	_, err = New(Options{ProxyURL: "://"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+)
+
+const (
+	// APIKeyName is the key of the Datadog API key in secrets, files and the environment
+	APIKeyName = "DD_API_KEY"
+	// AppKeyName is the key of the Datadog application key in secrets, files and the environment
+	AppKeyName = "DD_APP_KEY"
+	// SiteName is the key of the Datadog site in secrets, files and the environment
+	SiteName = "DD_SITE"
+)
+
+// siteAliases maps the names of the Datadog sites to their domains
+// More info: https://docs.datadoghq.com/getting_started/site/
+var siteAliases = map[string]string{
+	"us1":     "datadoghq.com",
+	"us3":     "us3.datadoghq.com",
+	"us5":     "us5.datadoghq.com",
+	"eu":      "datadoghq.eu",
+	"eu1":     "datadoghq.eu",
+	"us1-fed": "ddog-gov.com",
+}
+
+// Credentials identify the Datadog organization and site that is queried
+type Credentials struct {
+	APIKey string
+	AppKey string
+	// Site is the domain of the Datadog site, e.g. datadoghq.eu
+	Site string
+}
+
+// Provider resolves the credentials for a project and stage
+type Provider interface {
+	// Credentials returns the credentials for the stage of a project, or nil if the provider has none
+	Credentials(ctx context.Context, project, stage string) (*Credentials, error)
+}
+
+// Chain asks its providers one after the other and returns the first credentials found
+type Chain []Provider
+
+// Credentials returns the credentials of the first provider that has some for the project and stage
+func (c Chain) Credentials(ctx context.Context, project, stage string) (*Credentials, error) {
+	for _, provider := range c {
+		credentials, err := provider.Credentials(ctx, project, stage)
+		if err != nil || credentials != nil {
+			return credentials, err
+		}
+	}
+	return nil, nil
+}
+
+// Environment provides the service-wide credentials from the DD_API_KEY, DD_APP_KEY and DD_SITE env vars
+type Environment struct {
+	// Prefix of the env vars, e.g. EU_ for the EU_DD_API_KEY, EU_DD_APP_KEY and EU_DD_SITE env vars of a named instance
+	Prefix string
+}
+
+// Credentials returns the credentials defined by the environment, regardless of project and stage
+func (e Environment) Credentials(context.Context, string, string) (*Credentials, error) {
+	if os.Getenv(e.Prefix+APIKeyName) == "" && os.Getenv(e.Prefix+AppKeyName) == "" {
+		return nil, nil
+	}
+	return FromMap(map[string]string{
+		APIKeyName: os.Getenv(e.Prefix + APIKeyName),
+		AppKeyName: os.Getenv(e.Prefix + AppKeyName),
+		SiteName:   os.Getenv(e.Prefix + SiteName),
+	})
+}
+
+// InstancePrefix returns the prefix of the env vars with the credentials of a named Datadog instance, e.g. US_EAST_ for us-east
+func InstancePrefix(instance string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(instance)) + "_"
+}
+
+// FromMap builds credentials from the DD_API_KEY, DD_APP_KEY and DD_SITE entries of values
+func FromMap(values map[string]string) (*Credentials, error) {
+	credentials := &Credentials{
+		APIKey: strings.TrimSpace(values[APIKeyName]),
+		AppKey: strings.TrimSpace(values[AppKeyName]),
+		Site:   NormalizeSite(values[SiteName]),
+	}
+
+	missing := []string{}
+	if credentials.APIKey == "" {
+		missing = append(missing, APIKeyName)
+	}
+	if credentials.AppKey == "" {
+		missing = append(missing, AppKeyName)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("incomplete Datadog credentials, missing %s", strings.Join(missing, ", "))
+	}
+	return credentials, nil
+}
+
+// NormalizeSite converts the names of Datadog sites like US1, EU or US5 to their domains
+func NormalizeSite(site string) string {
+	site = strings.TrimSpace(site)
+	if domain, ok := siteAliases[strings.ToLower(site)]; ok {
+		return domain
+	}
+	return site
+}
+
+// AppURL returns the URL of the Datadog web app of the site, e.g. https://app.datadoghq.eu or https://us5.datadoghq.com
+func (c Credentials) AppURL() string {
+	site := c.Site
+	if site == "" {
+		site = "datadoghq.com"
+	}
+	// the first sites are served by app.<domain>, the newer ones by their own subdomain
+	if strings.Count(site, ".") == 1 {
+		return "https://app." + site
+	}
+	return "https://" + site
+}
+
+// Context returns a copy of ctx that authenticates Datadog API requests with the credentials
+func (c Credentials) Context(ctx context.Context) context.Context {
+	if c.Site != "" {
+		ctx = context.WithValue(ctx, datadog.ContextServerVariables, map[string]string{"site": c.Site})
+	}
+	return context.WithValue(ctx, datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: c.APIKey},
+		"appKeyAuth": {Key: c.AppKey},
+	})
+}
+
+// ErrNotFound is returned by Lookup if no credentials are defined for a project
+var ErrNotFound = errors.New("no Datadog credentials found")
+
+// Lookup resolves the credentials of the project and stage using provider, or the environment if provider is nil
+func Lookup(ctx context.Context, provider Provider, project, stage string) (*Credentials, error) {
+	if provider == nil {
+		provider = Environment{}
+	}
+
+	credentials, err := provider.Credentials(ctx, project, stage)
+	if err != nil {
+		return nil, err
+	}
+	if credentials == nil {
+		return nil, fmt.Errorf("%w for project '%s' and stage '%s'", ErrNotFound, project, stage)
+	}
+	return credentials, nil
+}
+
+// names returns the names credentials are looked up by, the most specific first: <project>-<stage> and <project>
+func names(project, stage string) []string {
+	if stage == "" {
+		return []string{project}
+	}
+	return []string{project + "-" + stage, project}
+}
@@ -0,0 +1,94 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticProvider struct {
+	credentials *Credentials
+	err         error
+}
+
+func (p staticProvider) Credentials(context.Context, string, string) (*Credentials, error) {
+	return p.credentials, p.err
+}
+
+func TestFromMap(t *testing.T) {
+	credentials, err := FromMap(map[string]string{APIKeyName: "api\n", AppKeyName: "app", SiteName: "EU"})
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "api", AppKey: "app", Site: "datadoghq.eu"}, credentials)
+
+	_, err = FromMap(map[string]string{SiteName: "datadoghq.com"})
+	assert.EqualError(t, err, "incomplete Datadog credentials, missing DD_API_KEY, DD_APP_KEY")
+}
+
+func TestNormalizeSite(t *testing.T) {
+	assert.Equal(t, "datadoghq.com", NormalizeSite("US1"))
+	assert.Equal(t, "us5.datadoghq.com", NormalizeSite("us5"))
+	assert.Equal(t, "us3.datadoghq.com", NormalizeSite("us3.datadoghq.com"))
+	assert.Equal(t, "", NormalizeSite(""))
+}
+
+func TestAppURL(t *testing.T) {
+	assert.Equal(t, "https://app.datadoghq.com", Credentials{}.AppURL())
+	assert.Equal(t, "https://app.datadoghq.eu", Credentials{Site: "datadoghq.eu"}.AppURL())
+	assert.Equal(t, "https://us5.datadoghq.com", Credentials{Site: "us5.datadoghq.com"}.AppURL())
+	assert.Equal(t, "https://app.ddog-gov.com", Credentials{Site: "ddog-gov.com"}.AppURL())
+}
+
+func TestChain(t *testing.T) {
+	project := &Credentials{APIKey: "project", AppKey: "project"}
+	fallback := &Credentials{APIKey: "fallback", AppKey: "fallback"}
+
+	credentials, err := Chain{staticProvider{}, staticProvider{credentials: project}, staticProvider{credentials: fallback}}.Credentials(context.Background(), "podtatohead", "hardening")
+	require.NoError(t, err)
+	assert.Equal(t, project, credentials)
+
+	_, err = Chain{staticProvider{err: errors.New("forbidden")}, staticProvider{credentials: fallback}}.Credentials(context.Background(), "podtatohead", "hardening")
+	assert.EqualError(t, err, "forbidden")
+}
+
+func TestLookup(t *testing.T) {
+	_, err := Lookup(context.Background(), Chain{staticProvider{}}, "podtatohead", "hardening")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	t.Setenv(APIKeyName, "env-api")
+	t.Setenv(AppKeyName, "env-app")
+	t.Setenv(SiteName, "datadoghq.com")
+	credentials, err := Lookup(context.Background(), nil, "podtatohead", "hardening")
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "env-api", AppKey: "env-app", Site: "datadoghq.com"}, credentials)
+}
+
+func TestContext(t *testing.T) {
+	ctx := Credentials{APIKey: "api", AppKey: "app", Site: "datadoghq.eu"}.Context(context.Background())
+
+	assert.Equal(t, map[string]string{"site": "datadoghq.eu"}, ctx.Value(datadog.ContextServerVariables))
+	assert.Equal(t, map[string]datadog.APIKey{"apiKeyAuth": {Key: "api"}, "appKeyAuth": {Key: "app"}}, ctx.Value(datadog.ContextAPIKeys))
+
+	url, err := datadog.NewConfiguration().ServerURLWithContext(ctx, "MetricsApiService.QueryMetrics")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.datadoghq.eu", url)
+}
+
+func TestEnvironmentWithPrefix(t *testing.T) {
+	assert.Equal(t, "US_EAST_", InstancePrefix("us-east"))
+
+	t.Setenv("EU_DD_API_KEY", "eu-api")
+	t.Setenv("EU_DD_APP_KEY", "eu-app")
+	t.Setenv("EU_DD_SITE", "EU")
+
+	credentials, err := Environment{Prefix: InstancePrefix("eu")}.Credentials(context.Background(), "podtatohead", "hardening")
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "eu-api", AppKey: "eu-app", Site: "datadoghq.eu"}, credentials)
+
+	credentials, err = Environment{Prefix: InstancePrefix("us5")}.Credentials(context.Background(), "podtatohead", "hardening")
+	require.NoError(t, err)
+	assert.Nil(t, credentials)
+}
@@ -0,0 +1,46 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory provides credentials from a mounted directory with a sub directory per project or stage,
+// e.g. <dir>/<project>-<stage>/DD_API_KEY or <dir>/<project>/DD_API_KEY, as created by mounting secrets as volumes
+type Directory struct {
+	Path string
+}
+
+// Credentials reads the credentials of the stage or, if there are none, of the project
+func (d Directory) Credentials(_ context.Context, project, stage string) (*Credentials, error) {
+	for _, name := range names(project, stage) {
+		dir := filepath.Join(d.Path, name)
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		values := map[string]string{}
+		for _, key := range []string{APIKeyName, AppKeyName, SiteName} {
+			content, err := os.ReadFile(filepath.Join(dir, key))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to read Datadog credentials of '%s': %w", name, err)
+			}
+			values[key] = strings.TrimSpace(string(content))
+		}
+
+		credentials, err := FromMap(values)
+		if err != nil {
+			return nil, fmt.Errorf("credentials directory '%s': %w", dir, err)
+		}
+		return credentials, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package credentials
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCredentials(t *testing.T, dir string, values map[string]string) {
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for key, value := range values {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, key), []byte(value), 0o600))
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeCredentials(t, filepath.Join(root, "podtatohead"), map[string]string{APIKeyName: "project-api", AppKeyName: "project-app\n"})
+	writeCredentials(t, filepath.Join(root, "podtatohead-production"), map[string]string{APIKeyName: "prod-api", AppKeyName: "prod-app", SiteName: "US5"})
+	writeCredentials(t, filepath.Join(root, "sockshop"), map[string]string{APIKeyName: "api"})
+
+	directory := Directory{Path: root}
+
+	credentials, err := directory.Credentials(context.Background(), "podtatohead", "hardening")
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "project-api", AppKey: "project-app"}, credentials)
+
+	credentials, err = directory.Credentials(context.Background(), "podtatohead", "production")
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "prod-api", AppKey: "prod-app", Site: "us5.datadoghq.com"}, credentials)
+
+	credentials, err = directory.Credentials(context.Background(), "unknown", "hardening")
+	require.NoError(t, err)
+	assert.Nil(t, credentials)
+
+	_, err = directory.Credentials(context.Background(), "sockshop", "hardening")
+	assert.ErrorContains(t, err, "missing DD_APP_KEY")
+}
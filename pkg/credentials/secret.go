@@ -0,0 +1,48 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// SecretPrefix is the prefix of the names of Keptn secrets with Datadog credentials
+const SecretPrefix = "datadog-credentials-"
+
+// Secrets provides credentials from Keptn secrets named datadog-credentials-<project>-<stage> or
+// datadog-credentials-<project> with the DD_API_KEY, DD_APP_KEY and DD_SITE keys
+type Secrets struct {
+	Client    typedcorev1.SecretsGetter
+	Namespace string
+}
+
+// Credentials reads the secret of the stage or, if there is none, of the project
+func (s Secrets) Credentials(ctx context.Context, project, stage string) (*Credentials, error) {
+	for _, name := range names(project, stage) {
+		secret, err := s.Client.Secrets(s.Namespace).Get(ctx, SecretPrefix+name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read secret '%s%s': %w", SecretPrefix, name, err)
+		}
+
+		values := map[string]string{}
+		for key, value := range secret.Data {
+			values[key] = string(value)
+		}
+		for key, value := range secret.StringData {
+			values[key] = value
+		}
+
+		credentials, err := FromMap(values)
+		if err != nil {
+			return nil, fmt.Errorf("secret '%s%s': %w", SecretPrefix, name, err)
+		}
+		return credentials, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,40 @@
+package credentials
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func secret(name string, data map[string]string) *corev1.Secret {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "keptn"}, Data: map[string][]byte{}}
+	for key, value := range data {
+		s.Data[key] = []byte(value)
+	}
+	return s
+}
+
+func TestSecrets(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		secret("datadog-credentials-podtatohead", map[string]string{APIKeyName: "project-api", AppKeyName: "project-app", SiteName: "datadoghq.eu"}),
+		secret("datadog-credentials-podtatohead-production", map[string]string{APIKeyName: "prod-api", AppKeyName: "prod-app"}),
+	)
+	secrets := Secrets{Client: clientset.CoreV1(), Namespace: "keptn"}
+
+	credentials, err := secrets.Credentials(context.Background(), "podtatohead", "hardening")
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "project-api", AppKey: "project-app", Site: "datadoghq.eu"}, credentials)
+
+	credentials, err = secrets.Credentials(context.Background(), "podtatohead", "production")
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{APIKey: "prod-api", AppKey: "prod-app"}, credentials)
+
+	credentials, err = secrets.Credentials(context.Background(), "sockshop", "production")
+	require.NoError(t, err)
+	assert.Nil(t, credentials)
+}
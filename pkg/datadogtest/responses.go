@@ -0,0 +1,113 @@
+package datadogtest
+
+import (
+	"net/http"
+	"time"
+)
+
+// Point is a value of a series at a time
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a series returned by the metrics endpoints
+type Series struct {
+	Metric string
+	Scope  string
+	Tags   []string
+	// Interval between two points
+	Interval time.Duration
+	Points   []Point
+}
+
+// Metrics returns a response of the v1 metrics query endpoint with the series
+func Metrics(series ...Series) Response {
+	body := map[string]interface{}{"status": "ok", "res_type": "time_series", "series": []interface{}{}}
+
+	list := []interface{}{}
+	for _, s := range series {
+		pointlist := [][]float64{}
+		for _, p := range s.Points {
+			pointlist = append(pointlist, []float64{float64(p.Time.UnixNano() / int64(time.Millisecond)), p.Value})
+		}
+		list = append(list, map[string]interface{}{
+			"metric":    s.Metric,
+			"scope":     s.Scope,
+			"tag_set":   nonNil(s.Tags),
+			"interval":  int64(s.Interval.Seconds()),
+			"pointlist": pointlist,
+			"length":    len(pointlist),
+		})
+	}
+	body["series"] = list
+	return Response{Body: body}
+}
+
+// Timeseries returns a response of the v2 timeseries query endpoint with the series of a formula,
+// all series must have points at the same times
+func Timeseries(series ...Series) Response {
+	times := []int64{}
+	if len(series) > 0 {
+		for _, p := range series[0].Points {
+			times = append(times, p.Time.UnixNano()/int64(time.Millisecond))
+		}
+	}
+
+	list := []interface{}{}
+	values := [][]float64{}
+	for _, s := range series {
+		list = append(list, map[string]interface{}{"group_tags": nonNil(s.Tags), "query_index": 0})
+		points := []float64{}
+		for _, p := range s.Points {
+			points = append(points, p.Value)
+		}
+		values = append(values, points)
+	}
+
+	return Response{Body: map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "timeseries_response",
+			"attributes": map[string]interface{}{
+				"series": list,
+				"times":  times,
+				"values": values,
+			},
+		},
+	}}
+}
+
+// Scalar returns a response of the v2 scalar query endpoint with a single value per group
+func Scalar(groups [][]string, values []float64) Response {
+	columns := []interface{}{}
+	if len(groups) > 0 {
+		columns = append(columns, map[string]interface{}{"name": "group", "type": "group", "values": groups})
+	}
+	columns = append(columns, map[string]interface{}{"name": "a", "type": "number", "values": values})
+
+	return Response{Body: map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "scalar_response",
+			"attributes": map[string]interface{}{"columns": columns},
+		},
+	}}
+}
+
+// Error returns an error response with the messages in the format used by Datadog
+func Error(statusCode int, messages ...string) Response {
+	return Response{StatusCode: statusCode, Body: map[string]interface{}{"errors": nonNil(messages)}}
+}
+
+// RateLimited returns a 429 response whose rate limit resets after the given seconds
+func RateLimited(reset int) Response {
+	response := Error(http.StatusTooManyRequests, "Too many requests")
+	response.RateLimit = &RateLimit{Limit: 100, Remaining: 0, Reset: reset}
+	return response
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,238 @@
+// Package datadogtest provides an in-process imitation of the Datadog API for tests that run without network access.
+package datadogtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+)
+
+// Response is a scripted answer of the Server
+type Response struct {
+	// StatusCode of the response, 200 if it is 0
+	StatusCode int
+	// Body is sent as is if it is a string or []byte and encoded as JSON otherwise
+	Body   interface{}
+	Header http.Header
+	// RateLimit adds the X-RateLimit-* headers to the response if it is not nil
+	RateLimit *RateLimit
+	// Delay is waited for before the response is sent, unless the request is cancelled before
+	Delay time.Duration
+}
+
+// RateLimit describes the rate limit headers of a response
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	// Reset is the number of seconds until the rate limit resets
+	Reset int
+}
+
+// Request is a request received by the Server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Server imitates the Datadog metrics, v2 query, monitor, SLO, dashboard and event endpoints. Responses can be scripted
+// per endpoint and, for the metrics endpoint, per query. Monitors, SLOs and dashboards that aren't scripted are kept in
+// memory, so they can be created, listed, updated and deleted like in Datadog, events can be posted and read.
+// Queries that aren't scripted return no series.
+type Server struct {
+	*httptest.Server
+	// APIKey and AppKey are required in the DD-API-KEY and DD-APPLICATION-KEY headers if they are set, events are
+	// posted with the API key only like in Datadog
+	APIKey string
+	AppKey string
+
+	mu         sync.Mutex
+	scripts    map[string][]Response
+	requests   []Request
+	monitors   *store
+	slos       *store
+	dashboards *store
+	events     *store
+}
+
+// NewServer starts a Server, it is stopped with Close
+func NewServer() *Server {
+	s := &Server{
+		scripts:    map[string][]Response{},
+		monitors:   newStore(monitorID),
+		slos:       newStore(sloID),
+		dashboards: newStore(dashboardID),
+		events:     newStore(eventID),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Configuration returns a Datadog client configuration that sends all requests to the server, regardless of the site
+func (s *Server) Configuration() *datadog.Configuration {
+	configuration := datadog.NewConfiguration()
+	configuration.Servers = datadog.ServerConfigurations{{URL: s.URL}}
+	configuration.OperationServers = map[string]datadog.ServerConfigurations{}
+	configuration.HTTPClient = s.Client()
+	return configuration
+}
+
+// APIClient returns a Datadog API client that sends all requests to the server
+func (s *Server) APIClient() *datadog.APIClient {
+	return datadog.NewAPIClient(s.Configuration())
+}
+
+// Script defines the responses to the requests of method to path. They are sent in order and the last one
+// is repeated once all others were sent.
+func (s *Server) Script(method, path string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[method+" "+path] = responses
+}
+
+// ScriptQuery defines the responses of the v1 metrics endpoint to query, it takes precedence over Script
+func (s *Server) ScriptQuery(query string, responses ...Response) {
+	s.Script(http.MethodGet, "/api/v1/query?query="+query, responses...)
+}
+
+// Requests returns the requests received for method and path, all requests if both are empty
+func (s *Server) Requests(method, path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := []Request{}
+	for _, r := range s.requests {
+		if (method == "" || r.Method == method) && (path == "" || r.Path == path) {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// Monitors returns the monitors stored by the server
+func (s *Server) Monitors() []map[string]interface{} {
+	return s.monitors.list()
+}
+
+// SLOs returns the service level objectives stored by the server
+func (s *Server) SLOs() []map[string]interface{} {
+	return s.slos.list()
+}
+
+// Dashboards returns the dashboards stored by the server
+func (s *Server) Dashboards() []map[string]interface{} {
+	return s.dashboards.list()
+}
+
+// Events returns the events posted to the server
+func (s *Server) Events() []map[string]interface{} {
+	return s.events.list()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone(), Body: body})
+	s.mu.Unlock()
+
+	postsEvent := r.Method == http.MethodPost && r.URL.Path == "/api/v1/events"
+	if (s.APIKey != "" && r.Header.Get("DD-API-KEY") != s.APIKey) || (s.AppKey != "" && !postsEvent && r.Header.Get("DD-APPLICATION-KEY") != s.AppKey) {
+		write(w, r, Error(http.StatusForbidden, "Forbidden"))
+		return
+	}
+
+	if r.URL.Path == "/api/v1/query" {
+		if response, ok := s.next(r.Method + " " + r.URL.Path + "?query=" + r.URL.Query().Get("query")); ok {
+			write(w, r, response)
+			return
+		}
+	}
+	if response, ok := s.next(r.Method + " " + r.URL.Path); ok {
+		write(w, r, response)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/api/v1/query":
+		write(w, r, Metrics())
+	case r.URL.Path == "/api/v2/query/timeseries" || r.URL.Path == "/api/v2/query/scalar":
+		write(w, r, Error(http.StatusBadRequest, "no response scripted for "+r.URL.Path))
+	default:
+		if response, ok := s.serveObjects(r, body); ok {
+			write(w, r, response)
+			return
+		}
+		write(w, r, Error(http.StatusNotFound, "Not found"))
+	}
+}
+
+// next returns the next scripted response for key
+func (s *Server) next(key string) (Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	responses := s.scripts[key]
+	if len(responses) == 0 {
+		return Response{}, false
+	}
+	if len(responses) > 1 {
+		s.scripts[key] = responses[1:]
+	}
+	return responses[0], true
+}
+
+func write(w http.ResponseWriter, r *http.Request, response Response) {
+	if response.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(response.Delay):
+		}
+	}
+
+	for name, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	if limit := response.RateLimit; limit != nil {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(limit.Reset))
+		w.Header().Set("X-RateLimit-Period", "60")
+	}
+
+	var body []byte
+	switch b := response.Body.(type) {
+	case nil:
+	case string:
+		body = []byte(b)
+	case []byte:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			panic(fmt.Sprintf("unable to encode the response body: %v", err))
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
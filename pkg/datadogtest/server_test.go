@@ -0,0 +1,97 @@
+package datadogtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptedQueries(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	server.ScriptQuery("avg:system.cpu.user{*}",
+		Error(http.StatusBadGateway, "Bad Gateway"),
+		Metrics(Series{Metric: "system.cpu.user", Interval: time.Minute, Points: []Point{{Time: time.Unix(1000, 0), Value: 42}}}),
+	)
+	client := server.APIClient()
+	ctx := context.Background()
+
+	_, r, err := client.MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.cpu.user{*}")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, r.StatusCode)
+
+	for i := 0; i < 2; i++ {
+		resp, _, err := client.MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.cpu.user{*}")
+		require.NoError(t, err, "the last response is repeated")
+		require.Len(t, resp.GetSeries(), 1)
+		assert.Equal(t, 42.0, *resp.GetSeries()[0].GetPointlist()[0][1])
+	}
+
+	resp, _, err := client.MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.mem.used{*}")
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetSeries(), "queries without script return no series")
+
+	requests := server.Requests(http.MethodGet, "/api/v1/query")
+	require.Len(t, requests, 4)
+	assert.Equal(t, "avg:system.mem.used{*}", requests[3].Query.Get("query"))
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(http.MethodGet, "/api/v1/query", RateLimited(7))
+
+	_, r, err := server.APIClient().MetricsApi.QueryMetrics(context.Background(), 1000, 1060, "avg:system.cpu.user{*}")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, r.StatusCode)
+	assert.Equal(t, "0", r.Header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "7", r.Header.Get("X-RateLimit-Reset"))
+}
+
+func TestLatency(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.Script(http.MethodGet, "/api/v1/query", Response{Delay: time.Second, Body: Metrics().Body})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := server.APIClient().MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.cpu.user{*}")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRequiredAPIKeys(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.APIKey, server.AppKey = "api-key", "app-key"
+
+	ctx := context.WithValue(context.Background(), datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "api-key"},
+		"appKeyAuth": {Key: "wrong"},
+	})
+	_, r, err := server.APIClient().MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.cpu.user{*}")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, r.StatusCode)
+
+	ctx = context.WithValue(context.Background(), datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "api-key"},
+		"appKeyAuth": {Key: "app-key"},
+	})
+	_, _, err = server.APIClient().MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.cpu.user{*}")
+	assert.NoError(t, err)
+}
+
+func TestConfigurationIgnoresSite(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	ctx := context.WithValue(context.Background(), datadog.ContextServerVariables, map[string]string{"site": "datadoghq.eu"})
+	_, _, err := server.APIClient().MetricsApi.QueryMetrics(ctx, 1000, 1060, "avg:system.cpu.user{*}")
+	assert.NoError(t, err)
+	assert.Len(t, server.Requests("", ""), 1)
+}
@@ -0,0 +1,318 @@
+package datadogtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// store keeps the objects of a Datadog API resource in memory
+type store struct {
+	// newID returns the ID of the nth object, monitors and events have numeric IDs, SLOs and dashboards string IDs
+	newID func(n int) interface{}
+
+	mu      sync.Mutex
+	lastID  int
+	objects map[string]map[string]interface{}
+}
+
+func newStore(newID func(n int) interface{}) *store {
+	return &store{newID: newID, objects: map[string]map[string]interface{}{}}
+}
+
+func monitorID(n int) interface{} {
+	return n
+}
+
+func sloID(n int) interface{} {
+	return fmt.Sprintf("slo%06d", n)
+}
+
+func dashboardID(n int) interface{} {
+	return fmt.Sprintf("abc-%03d-xyz", n)
+}
+
+func eventID(n int) interface{} {
+	return 1000000 + n
+}
+
+// list returns the objects ordered by creation
+func (s *store) list() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []map[string]interface{}{}
+	for _, object := range s.objects {
+		list = append(list, object)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a]["created_order"].(int) < list[b]["created_order"].(int)
+	})
+
+	objects := []map[string]interface{}{}
+	for _, object := range list {
+		objects = append(objects, withoutOrder(object))
+	}
+	return objects
+}
+
+func (s *store) get(id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects[id]
+	if !ok {
+		return nil, false
+	}
+	return withoutOrder(object), true
+}
+
+func (s *store) create(object map[string]interface{}) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	object["id"] = s.newID(s.lastID)
+	id := fmt.Sprint(object["id"])
+	if url, ok := object["url"]; ok {
+		object["url"] = strings.ReplaceAll(fmt.Sprint(url), "{id}", id)
+	}
+	object["created_order"] = s.lastID
+	s.objects[id] = object
+	return withoutOrder(object)
+}
+
+func (s *store) update(id string, object map[string]interface{}) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.objects[id]
+	if !ok {
+		return nil, false
+	}
+	object["id"] = existing["id"]
+	object["created_order"] = existing["created_order"]
+	if url, ok := existing["url"]; ok {
+		object["url"] = url
+	}
+	s.objects[id] = object
+	return withoutOrder(object), true
+}
+
+func (s *store) delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.objects[id]
+	delete(s.objects, id)
+	return ok
+}
+
+func withoutOrder(object map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		if key != "created_order" {
+			copied[key] = value
+		}
+	}
+	return copied
+}
+
+// hasTags checks if the tags of object contain all tags
+func hasTags(object map[string]interface{}, tags []string) bool {
+	objectTags := map[string]bool{}
+	if list, ok := object["tags"].([]interface{}); ok {
+		for _, tag := range list {
+			objectTags[fmt.Sprint(tag)] = true
+		}
+	}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !objectTags[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// serveObjects handles the monitor, SLO, dashboard and event endpoints
+func (s *Server) serveObjects(r *http.Request, body []byte) (Response, bool) {
+	switch {
+	case r.URL.Path == "/api/v1/monitor" || strings.HasPrefix(r.URL.Path, "/api/v1/monitor/"):
+		return serveMonitors(s.monitors, r, strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/monitor"), "/"), body), true
+	case r.URL.Path == "/api/v1/slo" || strings.HasPrefix(r.URL.Path, "/api/v1/slo/"):
+		return serveSLOs(s.slos, r, strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/slo"), "/"), body), true
+	case r.URL.Path == "/api/v1/dashboard" || strings.HasPrefix(r.URL.Path, "/api/v1/dashboard/"):
+		return serveDashboards(s.dashboards, r, strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/dashboard"), "/"), body), true
+	case r.URL.Path == "/api/v1/events" || strings.HasPrefix(r.URL.Path, "/api/v1/events/"):
+		return serveEvents(s.events, r, strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/events"), "/"), body), true
+	}
+	return Response{}, false
+}
+
+func serveMonitors(monitors *store, r *http.Request, id string, body []byte) Response {
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list := []map[string]interface{}{}
+		name := r.URL.Query().Get("name")
+		for _, monitor := range monitors.list() {
+			if hasTags(monitor, strings.Split(r.URL.Query().Get("monitor_tags"), ",")) && strings.Contains(fmt.Sprint(monitor["name"]), name) {
+				list = append(list, monitor)
+			}
+		}
+		return Response{Body: list}
+	case id == "" && r.Method == http.MethodPost:
+		monitor, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		return Response{Body: monitors.create(monitor)}
+	case r.Method == http.MethodGet:
+		if monitor, ok := monitors.get(id); ok {
+			return Response{Body: monitor}
+		}
+	case r.Method == http.MethodPut:
+		monitor, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		if monitor, ok := monitors.update(id, monitor); ok {
+			return Response{Body: monitor}
+		}
+	case r.Method == http.MethodDelete:
+		if monitors.delete(id) {
+			deleted, _ := strconv.Atoi(id)
+			return Response{Body: map[string]interface{}{"deleted_monitor_id": deleted}}
+		}
+	}
+	return Error(http.StatusNotFound, "Monitor not found")
+}
+
+func serveSLOs(slos *store, r *http.Request, id string, body []byte) Response {
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list := []map[string]interface{}{}
+		ids := r.URL.Query().Get("ids")
+		// tags_query is a search like env:production AND service:carts, only conjunctions of tags are supported
+		tags := strings.Split(strings.ReplaceAll(r.URL.Query().Get("tags_query"), " AND ", ","), ",")
+		for _, slo := range slos.list() {
+			if (ids == "" || contains(strings.Split(ids, ","), fmt.Sprint(slo["id"]))) && hasTags(slo, tags) &&
+				strings.Contains(fmt.Sprint(slo["name"]), r.URL.Query().Get("query")) {
+				list = append(list, slo)
+			}
+		}
+		return Response{Body: map[string]interface{}{"data": list}}
+	case id == "" && r.Method == http.MethodPost:
+		slo, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		return Response{Body: map[string]interface{}{"data": []interface{}{slos.create(slo)}}}
+	case r.Method == http.MethodGet:
+		if slo, ok := slos.get(id); ok {
+			return Response{Body: map[string]interface{}{"data": slo}}
+		}
+	case r.Method == http.MethodPut:
+		slo, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		if slo, ok := slos.update(id, slo); ok {
+			return Response{Body: map[string]interface{}{"data": []interface{}{slo}}}
+		}
+	case r.Method == http.MethodDelete:
+		if slos.delete(id) {
+			return Response{Body: map[string]interface{}{"data": []string{id}}}
+		}
+	}
+	return Error(http.StatusNotFound, "SLO not found")
+}
+
+func serveDashboards(dashboards *store, r *http.Request, id string, body []byte) Response {
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		// dashboards are listed as summaries without widgets
+		list := []map[string]interface{}{}
+		for _, dashboard := range dashboards.list() {
+			list = append(list, map[string]interface{}{
+				"id":          dashboard["id"],
+				"title":       dashboard["title"],
+				"description": dashboard["description"],
+				"layout_type": dashboard["layout_type"],
+				"url":         dashboard["url"],
+			})
+		}
+		return Response{Body: map[string]interface{}{"dashboards": list}}
+	case id == "" && r.Method == http.MethodPost:
+		dashboard, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		dashboard["url"] = "/dashboard/{id}/" + slug(fmt.Sprint(dashboard["title"]))
+		return Response{Body: dashboards.create(dashboard)}
+	case r.Method == http.MethodGet:
+		if dashboard, ok := dashboards.get(id); ok {
+			return Response{Body: dashboard}
+		}
+	case r.Method == http.MethodPut:
+		dashboard, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		if dashboard, ok := dashboards.update(id, dashboard); ok {
+			return Response{Body: dashboard}
+		}
+	case r.Method == http.MethodDelete:
+		if dashboards.delete(id) {
+			return Response{Body: map[string]interface{}{"deleted_dashboard_id": id}}
+		}
+	}
+	return Error(http.StatusNotFound, "Dashboard not found")
+}
+
+func serveEvents(events *store, r *http.Request, id string, body []byte) Response {
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		event, err := decode(body)
+		if err != nil {
+			return Error(http.StatusBadRequest, err.Error())
+		}
+		if event["title"] == nil || event["text"] == nil {
+			return Error(http.StatusBadRequest, "title and text are required")
+		}
+		event["url"] = "/event/event?id={id}"
+		return Response{StatusCode: http.StatusAccepted, Body: map[string]interface{}{"status": "ok", "event": events.create(event)}}
+	case r.Method == http.MethodGet:
+		if event, ok := events.get(id); ok {
+			return Response{Body: map[string]interface{}{"event": event}}
+		}
+	}
+	return Error(http.StatusNotFound, "Event not found")
+}
+
+// slug converts a title to the last part of a dashboard URL, e.g. keptn-sockshop-carts
+func slug(title string) string {
+	return strings.Trim(invalidSlugCharacters.ReplaceAllString(strings.ToLower(title), "-"), "-")
+}
+
+var invalidSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+func decode(body []byte) (map[string]interface{}, error) {
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return object, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
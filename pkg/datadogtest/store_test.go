@@ -0,0 +1,129 @@
+package datadogtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitors(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.APIClient()
+	ctx := context.Background()
+
+	monitor := datadog.NewMonitor("avg(last_5m):avg:system.cpu.user{*} > 80", datadog.MONITORTYPE_METRIC_ALERT)
+	monitor.SetName("cpu")
+	monitor.SetTags([]string{"keptn_project:sockshop", "keptn_service:carts"})
+	created, _, err := client.MonitorsApi.CreateMonitor(ctx, *monitor)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.GetId())
+
+	other := datadog.NewMonitor("avg(last_5m):avg:system.mem.used{*} > 80", datadog.MONITORTYPE_METRIC_ALERT)
+	other.SetTags([]string{"keptn_project:other"})
+	_, _, err = client.MonitorsApi.CreateMonitor(ctx, *other)
+	require.NoError(t, err)
+
+	listed, _, err := client.MonitorsApi.ListMonitors(ctx, *datadog.NewListMonitorsOptionalParameters().WithMonitorTags("keptn_project:sockshop,keptn_service:carts"))
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "cpu", listed[0].GetName())
+
+	update := datadog.NewMonitorUpdateRequest()
+	update.SetQuery("avg(last_5m):avg:system.cpu.user{*} > 90")
+	update.SetType(datadog.MONITORTYPE_METRIC_ALERT)
+	updated, _, err := client.MonitorsApi.UpdateMonitor(ctx, created.GetId(), *update)
+	require.NoError(t, err)
+	assert.Equal(t, "avg(last_5m):avg:system.cpu.user{*} > 90", updated.GetQuery())
+
+	_, _, err = client.MonitorsApi.DeleteMonitor(ctx, created.GetId())
+	require.NoError(t, err)
+	assert.Len(t, server.Monitors(), 1)
+
+	_, r, err := client.MonitorsApi.GetMonitor(ctx, created.GetId())
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, r.StatusCode)
+}
+
+func TestSLOs(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.APIClient()
+	ctx := context.Background()
+
+	slo := datadog.NewServiceLevelObjectiveRequest("carts availability", []datadog.SLOThreshold{{Target: 99.9, Timeframe: datadog.SLOTIMEFRAME_SEVEN_DAYS}}, datadog.SLOTYPE_METRIC)
+	slo.SetQuery(*datadog.NewServiceLevelObjectiveQuery("sum:requests.error{*}", "sum:requests{*}"))
+	slo.SetTags([]string{"keptn_project:sockshop"})
+	created, _, err := client.ServiceLevelObjectivesApi.CreateSLO(ctx, *slo)
+	require.NoError(t, err)
+	require.Len(t, created.GetData(), 1)
+	id := created.GetData()[0].GetId()
+
+	listed, _, err := client.ServiceLevelObjectivesApi.ListSLOs(ctx, *datadog.NewListSLOsOptionalParameters().WithTagsQuery("keptn_project:sockshop"))
+	require.NoError(t, err)
+	require.Len(t, listed.GetData(), 1)
+	assert.Equal(t, id, listed.GetData()[0].GetId())
+
+	listed, _, err = client.ServiceLevelObjectivesApi.ListSLOs(ctx, *datadog.NewListSLOsOptionalParameters().WithTagsQuery("keptn_project:other"))
+	require.NoError(t, err)
+	assert.Empty(t, listed.GetData())
+
+	_, _, err = client.ServiceLevelObjectivesApi.DeleteSLO(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, server.SLOs())
+}
+
+func TestDashboards(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.APIClient()
+	ctx := context.Background()
+
+	dashboard := datadog.NewDashboard(datadog.DASHBOARDLAYOUTTYPE_ORDERED, "Keptn: sockshop / carts", []datadog.Widget{})
+	created, _, err := client.DashboardsApi.CreateDashboard(ctx, *dashboard)
+	require.NoError(t, err)
+	assert.Equal(t, "/dashboard/"+created.GetId()+"/keptn-sockshop-carts", created.GetUrl())
+
+	listed, _, err := client.DashboardsApi.ListDashboards(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed.GetDashboards(), 1)
+	assert.Equal(t, "Keptn: sockshop / carts", listed.GetDashboards()[0].GetTitle())
+
+	dashboard.SetDescription("updated")
+	updated, _, err := client.DashboardsApi.UpdateDashboard(ctx, created.GetId(), *dashboard)
+	require.NoError(t, err)
+	assert.Equal(t, created.GetUrl(), updated.GetUrl())
+	assert.Equal(t, "updated", updated.GetDescription())
+
+	_, _, err = client.DashboardsApi.DeleteDashboard(ctx, created.GetId())
+	require.NoError(t, err)
+	assert.Empty(t, server.Dashboards())
+}
+
+func TestEvents(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.APIClient()
+	ctx := context.Background()
+
+	event := datadog.NewEventCreateRequest("v2 deployed", "Keptn deployment of carts")
+	event.SetTags([]string{"keptn_project:sockshop"})
+	_, _, err := client.EventsApi.CreateEvent(ctx, *event)
+	require.NoError(t, err)
+
+	events := server.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "Keptn deployment of carts", events[0]["title"])
+	assert.Equal(t, []interface{}{"keptn_project:sockshop"}, events[0]["tags"])
+
+	read, _, err := client.EventsApi.GetEvent(ctx, 1000001)
+	require.NoError(t, err)
+	assert.Equal(t, "v2 deployed", read.Event.GetText())
+
+	_, _, err = client.EventsApi.GetEvent(ctx, 42)
+	assert.Error(t, err)
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+)
+
+// Backend queries the series of an indicator
+type Backend interface {
+	// Query returns the series of the indicator between start and end. The response of the request is
+	// returned for the rate limit headers, it is nil if the backend didn't send a request.
+	Query(ctx context.Context, indicator sli.Indicator, start, end time.Time) ([]sli.Series, *http.Response, error)
+}
+
+// Datadog queries indicators with the Datadog metrics API, the v2 query API is used for indicators with api_version v2
+type Datadog struct {
+	Client *datadog.APIClient
+}
+
+// Query sends the indicator queries to the Datadog query API selected by the indicator
+func (d Datadog) Query(ctx context.Context, indicator sli.Indicator, start, end time.Time) ([]sli.Series, *http.Response, error) {
+	if indicator.APIVersion == sli.APIVersionV2 {
+		series, r, err := QueryFormula(ctx, d.Client.GetConfig(), start, end, FormulaQuery{
+			Queries:    indicator.Queries,
+			Formula:    indicator.Formula,
+			Aggregator: indicator.QueryAggregator,
+		})
+		return series, r, NewAPIError(err, r)
+	}
+
+	resp, r, err := d.Client.MetricsApi.QueryMetrics(ctx, start.Unix(), end.Unix(), indicator.Query)
+	if err != nil {
+		return nil, r, NewAPIError(err, r)
+	}
+	return SeriesFromResponse(resp), r, nil
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatadogQueryV1(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		query = r.URL.Query().Get("query")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok", "series": [{"interval": 60, "pointlist": [[1000000, 1.5], [1060000, 2.5]]}]}`))
+	}))
+	defer server.Close()
+
+	backend := Datadog{Client: datadog.NewAPIClient(datadog.NewConfiguration())}
+	series, r, err := backend.Query(testContext(t, server), sli.Indicator{Query: "avg:system.cpu.user{*}"}, time.Unix(1000, 0), time.Unix(1120, 0))
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "avg:system.cpu.user{*}", query)
+	require.Len(t, series, 1)
+	assert.Len(t, series[0].Points, 2)
+	assert.Equal(t, time.Minute, series[0].Interval)
+}
+
+func TestDatadogQueryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors": ["Error parsing query"]}`))
+	}))
+	defer server.Close()
+
+	backend := Datadog{Client: datadog.NewAPIClient(datadog.NewConfiguration())}
+	_, _, err := backend.Query(testContext(t, server), sli.Indicator{Query: "avg:"}, time.Unix(1000, 0), time.Unix(1120, 0))
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, []string{"Error parsing query"}, apiErr.Messages)
+}
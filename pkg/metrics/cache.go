@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+)
+
+// Cache is a Backend that keeps the series returned by another backend, so e.g. the baseline windows
+// that are shared by consecutive evaluations are only queried once
+type Cache struct {
+	Backend Backend
+	Clock   Clock
+	// TTL is the time a result is kept
+	TTL time.Duration
+	// Settled is the time after which Datadog reflects all data of a window, windows that ended
+	// more recently are never cached as their data may still change
+	Settled time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	series  []sli.Series
+	expires time.Time
+}
+
+// Query returns the cached series of the indicator or queries and caches them
+func (c *Cache) Query(ctx context.Context, indicator sli.Indicator, start, end time.Time) ([]sli.Series, *http.Response, error) {
+	now := c.Clock.Now()
+	if c.TTL <= 0 || end.After(now.Add(-c.Settled)) {
+		return c.Backend.Query(ctx, indicator, start, end)
+	}
+
+	key := cacheKey(ctx, indicator, start, end)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.series, nil, nil
+	}
+
+	series, r, err := c.Backend.Query(ctx, indicator, start, end)
+	if err != nil {
+		return series, r, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]cacheEntry{}
+	}
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{series: series, expires: now.Add(c.TTL)}
+	return series, r, nil
+}
+
+// cacheKey identifies the queries of the indicator in the window for the Datadog organization and site of ctx.
+// It is hashed so the cache doesn't hold the API keys.
+func cacheKey(ctx context.Context, indicator sli.Indicator, start, end time.Time) string {
+	key, _ := json.Marshal(struct {
+		APIKeys    interface{}
+		Site       interface{}
+		APIVersion string
+		Query      string
+		Queries    map[string]string
+		Formula    string
+		Aggregator string
+		Start      int64
+		End        int64
+	}{
+		APIKeys:    ctx.Value(datadog.ContextAPIKeys),
+		Site:       ctx.Value(datadog.ContextServerVariables),
+		APIVersion: indicator.APIVersion,
+		Query:      indicator.Query,
+		Queries:    indicator.Queries,
+		Formula:    indicator.Formula,
+		Aggregator: indicator.QueryAggregator,
+		Start:      start.Unix(),
+		End:        end.Unix(),
+	})
+	hash := sha256.Sum256(key)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend returns a new series on every query
+type countingBackend struct {
+	queries int
+	err     error
+}
+
+func (b *countingBackend) Query(context.Context, sli.Indicator, time.Time, time.Time) ([]sli.Series, *http.Response, error) {
+	b.queries++
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+	value := float64(b.queries)
+	return []sli.Series{{Points: [][]*float64{{&value, &value}}}}, &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func withAPIKey(key string) context.Context {
+	return context.WithValue(context.Background(), datadog.ContextAPIKeys, map[string]datadog.APIKey{"apiKeyAuth": {Key: key}})
+}
+
+func TestCacheReturnsCachedSeries(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(100000, 0)}
+	backend := &countingBackend{}
+	cache := &Cache{Backend: backend, Clock: clock, TTL: time.Hour, Settled: 2 * time.Minute}
+
+	indicator := sli.Indicator{Query: "avg:system.cpu.user{*}"}
+	start, end := time.Unix(10000, 0), time.Unix(20000, 0)
+
+	first, r, err := cache.Query(withAPIKey("key"), indicator, start, end)
+	require.NoError(t, err)
+	assert.NotNil(t, r)
+
+	cached, r, err := cache.Query(withAPIKey("key"), indicator, start, end)
+	require.NoError(t, err)
+	assert.Nil(t, r, "no request is sent for cached series")
+	assert.Equal(t, first, cached)
+	assert.Equal(t, 1, backend.queries)
+
+	// other organizations, queries and windows are queried separately
+	cache.Query(withAPIKey("other-key"), indicator, start, end)
+	cache.Query(withAPIKey("key"), sli.Indicator{Query: "avg:system.mem.used{*}"}, start, end)
+	cache.Query(withAPIKey("key"), indicator, start, end.Add(time.Minute))
+	assert.Equal(t, 4, backend.queries)
+
+	clock.now = clock.now.Add(time.Hour)
+	cache.Query(withAPIKey("key"), indicator, start, end)
+	assert.Equal(t, 5, backend.queries, "expired series are queried again")
+}
+
+func TestCacheSkipsRecentWindows(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(100000, 0)}
+	backend := &countingBackend{}
+	cache := &Cache{Backend: backend, Clock: clock, TTL: time.Hour, Settled: 2 * time.Minute}
+
+	indicator := sli.Indicator{Query: "avg:system.cpu.user{*}"}
+	end := clock.now.Add(-time.Minute)
+	cache.Query(withAPIKey("key"), indicator, end.Add(-time.Hour), end)
+	cache.Query(withAPIKey("key"), indicator, end.Add(-time.Hour), end)
+	assert.Equal(t, 2, backend.queries)
+}
+
+func TestCacheSkipsErrors(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(100000, 0)}
+	backend := &countingBackend{err: errors.New("502 Bad Gateway")}
+	cache := &Cache{Backend: backend, Clock: clock, TTL: time.Hour}
+
+	indicator := sli.Indicator{Query: "avg:system.cpu.user{*}"}
+	start, end := time.Unix(10000, 0), time.Unix(20000, 0)
+	_, _, err := cache.Query(withAPIKey("key"), indicator, start, end)
+	assert.Error(t, err)
+	_, _, err = cache.Query(withAPIKey("key"), indicator, start, end)
+	assert.Error(t, err)
+	assert.Equal(t, 2, backend.queries)
+}
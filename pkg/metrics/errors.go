@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned when the Datadog API answers a request with an error status code
+type APIError struct {
+	StatusCode int
+	// Messages are the errors reported by Datadog in the response body
+	Messages []string
+	Err      error
+}
+
+// NewAPIError wraps err with the status code of r and the error messages of the response body,
+// err is returned unchanged if there is no response or it already is an APIError
+func NewAPIError(err error, r *http.Response) error {
+	var apiErr *APIError
+	if err == nil || r == nil || errors.As(err, &apiErr) {
+		return err
+	}
+	return &APIError{StatusCode: r.StatusCode, Messages: errorMessages(err), Err: err}
+}
+
+// Error summarizes the error with the status and the messages reported by Datadog, e.g. 403 Forbidden: Forbidden
+func (e *APIError) Error() string {
+	if len(e.Messages) == 0 {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + strings.Join(e.Messages, ", ")
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// IsAuthError checks if the request was rejected because of missing, invalid or insufficient API keys
+func (e *APIError) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// errorMessages returns the errors of the Datadog error response in the body of err
+// More info: https://docs.datadoghq.com/api/latest/#errors
+func errorMessages(err error) []string {
+	var withBody interface{ Body() []byte }
+	if !errors.As(err, &withBody) {
+		return nil
+	}
+
+	response := datadogErrorResponse{}
+	if json.Unmarshal(withBody.Body(), &response) != nil {
+		return nil
+	}
+	return response.Errors
+}
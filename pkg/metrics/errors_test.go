@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIErrorWithDatadogMessages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors": ["Forbidden"]}`))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	configuration := datadog.NewConfiguration()
+	configuration.Host = serverURL.Host
+	configuration.Scheme = serverURL.Scheme
+	ctx := context.WithValue(context.Background(), datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "0123456789abcdef"},
+		"appKeyAuth": {Key: "fedcba9876543210"},
+	})
+
+	_, r, err := datadog.NewAPIClient(configuration).MetricsApi.QueryMetrics(ctx, 0, 60, "avg:system.cpu.user{*}")
+	err = NewAPIError(err, r)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, []string{"Forbidden"}, apiErr.Messages)
+	assert.Equal(t, "403 Forbidden: Forbidden", err.Error())
+	assert.NotContains(t, err.Error(), "0123456789abcdef")
+}
+
+func TestNewAPIError(t *testing.T) {
+	assert.Nil(t, NewAPIError(nil, &http.Response{StatusCode: http.StatusOK}))
+
+	plain := errors.New("connection reset")
+	assert.Equal(t, plain, NewAPIError(plain, nil))
+
+	apiErr := &APIError{StatusCode: http.StatusBadRequest, Messages: []string{"invalid query"}, Err: errors.New("400 Bad Request")}
+	assert.Equal(t, apiErr, NewAPIError(apiErr, &http.Response{StatusCode: http.StatusBadGateway}))
+	assert.Equal(t, "400 Bad Request: invalid query", apiErr.Error())
+}
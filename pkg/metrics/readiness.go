@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+)
+
+// Clock abstracts the passing of time so that waiting for data can be tested without real sleeps
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RealClock is the Clock backed by the time package
+var RealClock Clock = realClock{}
+
+// MinPollInterval is the shortest time waited between two polls, shorter intervals would use up the rate limit
+const MinPollInterval = time.Second
+
+// QueryFunc sends a single query to the Datadog API
+type QueryFunc func() ([]sli.Series, error)
+
+// Poller repeats a metrics query until Datadog has ingested the data of the requested time window
+// Datadog ingests metrics with a delay, so querying right after the end of the window returns incomplete data
+// More info: https://github.com/keptn-sandbox/datadog-service/issues/8
+type Poller struct {
+	Clock Clock
+	// Interval is the time to wait between two polls, at least MinPollInterval
+	Interval time.Duration
+	// MaxWait is the maximum time to wait for the data to become ready
+	MaxWait time.Duration
+}
+
+// WaitForData polls query until the returned point lists cover end or their values stop changing between two polls.
+// Two consecutive empty responses count as settled too, so queries without data don't wait for MaxWait.
+// If neither happens within MaxWait, the last response is returned with ready set to false.
+// Errors returned by query are passed on immediately, as is the error of ctx if it is done while waiting. Both come
+// with the last successful response, so the caller can still use the data that was ready before e.g. a timeout.
+func (p Poller) WaitForData(ctx context.Context, end time.Time, query QueryFunc) (series []sli.Series, ready bool, err error) {
+	deadline := p.Clock.Now().Add(p.MaxWait)
+
+	var previous []sli.Series
+	for polls := 0; ; polls++ {
+		polled, err := query()
+		if err != nil {
+			return series, false, err
+		}
+		series = polled
+
+		if coversEnd(series, end) {
+			return series, true, nil
+		}
+
+		if polls > 0 && samePoints(previous, series) {
+			return series, true, nil
+		}
+
+		now := p.Clock.Now()
+		if !now.Before(deadline) {
+			return series, false, nil
+		}
+
+		// checked before waiting, as select picks randomly if the context is done and the clock fired already
+		if err := ctx.Err(); err != nil {
+			return series, false, err
+		}
+
+		wait := p.Interval
+		if wait < MinPollInterval {
+			wait = MinPollInterval
+		}
+		if remaining := deadline.Sub(now); remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return series, false, ctx.Err()
+		case <-p.Clock.After(wait):
+		}
+
+		previous = series
+	}
+}
+
+// coversEnd checks if every returned series has a point whose interval reaches end
+func coversEnd(series []sli.Series, end time.Time) bool {
+	if !hasPoints(series) {
+		return false
+	}
+
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			return false
+		}
+
+		last := s.Points[len(s.Points)-1]
+		if len(last) == 0 || last[0] == nil {
+			return false
+		}
+
+		lastPointEnd := int64(*last[0]) + s.Interval.Milliseconds()
+		if lastPointEnd < end.UnixNano()/int64(time.Millisecond) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasPoints(series []sli.Series) bool {
+	for _, s := range series {
+		if len(s.Points) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func samePoints(a, b []sli.Series) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !reflect.DeepEqual(a[i].Points, b[i].Points) {
+			return false
+		}
+	}
+	return true
+}
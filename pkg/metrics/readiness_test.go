@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances its time instantly whenever it is asked to wait
+type fakeClock struct {
+	now    time.Time
+	waited time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	c.waited += d
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// response builds a single series from timestamps (in seconds) and values
+func response(interval int64, timestamps []int64, values []float64) []sli.Series {
+	points := [][]*float64{}
+	for i := range timestamps {
+		ts := float64(timestamps[i] * 1000)
+		value := values[i]
+		points = append(points, []*float64{&ts, &value})
+	}
+	return []sli.Series{{Points: points, Interval: time.Duration(interval) * time.Second}}
+}
+
+// scriptedQuery returns the given responses one after the other and repeats the last one
+func scriptedQuery(responses ...[]sli.Series) (QueryFunc, *int) {
+	calls := 0
+	return func() ([]sli.Series, error) {
+		resp := responses[len(responses)-1]
+		if calls < len(responses) {
+			resp = responses[calls]
+		}
+		calls++
+		return resp, nil
+	}, &calls
+}
+
+// changingQuery returns a response whose value changes with every call, so it never settles
+func changingQuery() (QueryFunc, *int) {
+	calls := 0
+	return func() ([]sli.Series, error) {
+		calls++
+		return response(20, []int64{900}, []float64{float64(calls)}), nil
+	}, &calls
+}
+
+func TestWaitForDataReturnsOnceEndIsCovered(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 2 * time.Minute}
+
+	query, calls := scriptedQuery(
+		[]sli.Series{},
+		response(20, []int64{900, 920}, []float64{1, 2}),
+		response(20, []int64{900, 920, 940, 960, 980}, []float64{1, 2, 3, 4, 5}),
+	)
+
+	resp, ready, err := poller.WaitForData(context.Background(), time.Unix(1000, 0), query)
+	require.NoError(t, err)
+	assert.True(t, ready)
+	assert.Equal(t, 3, *calls)
+	assert.Len(t, resp[0].Points, 5)
+	assert.Equal(t, 20*time.Second, clock.waited)
+}
+
+func TestWaitForDataReturnsOnceValuesAreStable(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 2 * time.Minute}
+
+	query, calls := scriptedQuery(
+		response(20, []int64{900}, []float64{1}),
+		response(20, []int64{900}, []float64{3}),
+		response(20, []int64{900}, []float64{3}),
+	)
+
+	_, ready, err := poller.WaitForData(context.Background(), time.Unix(1000, 0), query)
+	require.NoError(t, err)
+	assert.True(t, ready)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestWaitForDataReturnsOnceResponsesStayEmpty(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 2 * time.Minute}
+
+	query, calls := scriptedQuery(nil, []sli.Series{})
+
+	series, ready, err := poller.WaitForData(context.Background(), time.Unix(1000, 0), query)
+	require.NoError(t, err)
+	assert.True(t, ready)
+	assert.Empty(t, series)
+	assert.Equal(t, 2, *calls)
+	assert.Equal(t, 10*time.Second, clock.waited)
+}
+
+func TestWaitForDataGivesUpAfterMaxWait(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 25 * time.Second}
+
+	query, calls := changingQuery()
+
+	_, ready, err := poller.WaitForData(context.Background(), time.Unix(1000, 0), query)
+	require.NoError(t, err)
+	assert.False(t, ready)
+	assert.Equal(t, 4, *calls)
+	assert.Equal(t, 25*time.Second, clock.waited)
+}
+
+func TestWaitForDataWaitsAtLeastMinPollInterval(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, MaxWait: 5 * time.Second}
+
+	query, calls := changingQuery()
+
+	_, ready, err := poller.WaitForData(context.Background(), time.Unix(1000, 0), query)
+	require.NoError(t, err)
+	assert.False(t, ready)
+	assert.Equal(t, 6, *calls)
+	assert.Equal(t, 5*time.Second, clock.waited)
+}
+
+func TestWaitForDataReturnsErrorsImmediately(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 2 * time.Minute}
+
+	_, ready, err := poller.WaitForData(context.Background(), time.Unix(1000, 0), func() ([]sli.Series, error) {
+		return nil, errors.New("403 Forbidden")
+	})
+	assert.Error(t, err)
+	assert.False(t, ready)
+	assert.Zero(t, clock.waited)
+}
+
+func TestWaitForDataStopsWhenContextIsDone(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 2 * time.Minute}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	query, calls := scriptedQuery([]sli.Series{})
+	_, ready, err := poller.WaitForData(ctx, time.Unix(1000, 0), query)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, ready)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestWaitForDataReturnsLastResponseWithError(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	poller := Poller{Clock: clock, Interval: 10 * time.Second, MaxWait: 2 * time.Minute}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := response(60, []int64{840}, []float64{1})
+	calls := 0
+	series, ready, err := poller.WaitForData(ctx, time.Unix(1000, 0), func() ([]sli.Series, error) {
+		calls++
+		if calls == 1 {
+			return first, nil
+		}
+		// the timeout is reached during the second query
+		cancel()
+		return nil, ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, ready)
+	assert.Equal(t, first, series)
+}
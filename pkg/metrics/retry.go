@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RequestFunc sends a single request to the Datadog API, attempt starts at 1
+type RequestFunc func(attempt int) (*http.Response, error)
+
+// Retrier repeats requests that failed because of transient errors using exponential backoff with jitter
+// and waits for the Datadog rate limit to reset instead of sending requests that would be rejected
+type Retrier struct {
+	Clock Clock
+	// MaxAttempts is the maximum number of attempts including the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles with every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay between two attempts
+	MaxDelay time.Duration
+	// RateLimit is shared by all requests to the same Datadog organization, it may be nil
+	RateLimit *RateLimit
+	// Jitter returns a random number in [0, 1), rand.Float64 is used if it is nil
+	Jitter func() float64
+	// OnRetry is called with the failed attempt, its error and the delay before the next attempt, it may be nil
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// Do calls request until it succeeds, fails with an error that is not transient, MaxAttempts is reached or ctx is done
+func (r Retrier) Do(ctx context.Context, request RequestFunc) error {
+	maxAttempts := r.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if r.RateLimit != nil {
+			if err := r.sleep(ctx, r.RateLimit.wait(r.Clock.Now())); err != nil {
+				return err
+			}
+		}
+
+		resp, err := request(attempt)
+		var resetIn time.Duration
+		if r.RateLimit != nil {
+			resetIn = r.RateLimit.update(r.Clock.Now(), resp)
+		}
+
+		if err == nil || attempt >= maxAttempts || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := r.backoff(attempt)
+		if isRateLimited(err) && resetIn > 0 {
+			// the rate limit is waited for at the beginning of the next attempt
+			delay = 0
+		}
+
+		if r.OnRetry != nil {
+			r.OnRetry(attempt, err, delay+resetIn)
+		}
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the delay after the given attempt, a random value between half and the full exponential delay
+func (r Retrier) backoff(attempt int) time.Duration {
+	delay := float64(r.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if r.MaxDelay > 0 && delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
+	}
+
+	jitter := rand.Float64
+	if r.Jitter != nil {
+		jitter = r.Jitter
+	}
+	return time.Duration(delay/2 + jitter()*delay/2)
+}
+
+func (r Retrier) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.Clock.After(d):
+		return nil
+	}
+}
+
+// IsTransient checks if a request that failed with err may succeed when it is repeated:
+// rate limited requests, server errors, timeouts and connection resets
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// RateLimit tracks the Datadog rate limit from the X-RateLimit-Remaining and X-RateLimit-Reset response headers
+// More info: https://docs.datadoghq.com/api/latest/rate-limits/
+type RateLimit struct {
+	mu      sync.Mutex
+	resetAt time.Time
+}
+
+// update records the rate limit of resp and returns the time until it resets if no requests are remaining
+func (l *RateLimit) update(now time.Time, resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" && resp.StatusCode != http.StatusTooManyRequests {
+		return 0
+	}
+	if n, err := strconv.Atoi(remaining); err == nil && n > 0 && resp.StatusCode != http.StatusTooManyRequests {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Reset"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	resetIn := time.Duration(seconds) * time.Second
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if resetAt := now.Add(resetIn); resetAt.After(l.resetAt) {
+		l.resetAt = resetAt
+	}
+	return resetIn
+}
+
+// wait returns the time until the rate limit resets
+func (l *RateLimit) wait(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.resetAt.Sub(now)
+}
+
+// RateLimits keeps a RateLimit per Datadog organization, as the limits of one organization don't affect another one
+type RateLimits struct {
+	mu     sync.Mutex
+	limits map[string]*RateLimit
+}
+
+// For returns the RateLimit of the organization identified by key, e.g. its site and API key
+func (r *RateLimits) For(key string) *RateLimit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limits == nil {
+		r.limits = map[string]*RateLimit{}
+	}
+	limit, ok := r.limits[key]
+	if !ok {
+		limit = &RateLimit{}
+		r.limits[key] = limit
+	}
+	return limit
+}
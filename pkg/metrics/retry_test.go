@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRetrier(clock *fakeClock) Retrier {
+	return Retrier{
+		Clock:       clock,
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    3 * time.Second,
+		RateLimit:   &RateLimit{},
+		Jitter:      func() float64 { return 1 },
+	}
+}
+
+// scriptedRequest fails with the given errors one after the other and succeeds afterwards
+func scriptedRequest(errs ...error) (RequestFunc, *[]int) {
+	attempts := []int{}
+	return func(attempt int) (*http.Response, error) {
+		attempts = append(attempts, attempt)
+		if len(attempts) <= len(errs) {
+			return nil, errs[len(attempts)-1]
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+	}, &attempts
+}
+
+func TestRetrierRetriesTransientErrors(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	retrier := newTestRetrier(clock)
+	retried := []int{}
+	retrier.OnRetry = func(attempt int, err error, delay time.Duration) {
+		retried = append(retried, attempt)
+	}
+
+	request, attempts := scriptedRequest(
+		&APIError{StatusCode: http.StatusBadGateway, Err: errors.New("502 Bad Gateway")},
+		fmt.Errorf("read: %w", syscall.ECONNRESET),
+		&APIError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("503 Service Unavailable")},
+	)
+
+	assert.NoError(t, retrier.Do(context.Background(), request))
+	assert.Equal(t, []int{1, 2, 3, 4}, *attempts)
+	assert.Equal(t, []int{1, 2, 3}, retried)
+	// 1s, 2s and 4s capped at 3s
+	assert.Equal(t, 6*time.Second, clock.waited)
+}
+
+func TestRetrierStopsAfterMaxAttempts(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	retrier := newTestRetrier(clock)
+	retrier.MaxAttempts = 2
+
+	serverError := &APIError{StatusCode: http.StatusInternalServerError, Err: errors.New("500 Internal Server Error")}
+	request, attempts := scriptedRequest(serverError, serverError, serverError)
+
+	assert.Equal(t, serverError, retrier.Do(context.Background(), request))
+	assert.Len(t, *attempts, 2)
+}
+
+func TestRetrierDoesNotRetryPermanentErrors(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	retrier := newTestRetrier(clock)
+
+	badRequest := &APIError{StatusCode: http.StatusBadRequest, Err: errors.New("400 Bad Request")}
+	request, attempts := scriptedRequest(badRequest)
+
+	assert.Equal(t, badRequest, retrier.Do(context.Background(), request))
+	assert.Len(t, *attempts, 1)
+	assert.Zero(t, clock.waited)
+}
+
+func TestRetrierWaitsForRateLimitReset(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	retrier := newTestRetrier(clock)
+
+	calls := 0
+	err := retrier.Do(context.Background(), func(attempt int) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			header := http.Header{}
+			header.Set("X-RateLimit-Remaining", "0")
+			header.Set("X-RateLimit-Reset", "12")
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}, &APIError{StatusCode: http.StatusTooManyRequests, Err: errors.New("429 Too Many Requests")}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 12*time.Second, clock.waited)
+}
+
+func TestRateLimitIsSharedBetweenRequests(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	retrier := newTestRetrier(clock)
+
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "5")
+	err := retrier.Do(context.Background(), func(int) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: header}, nil
+	})
+	assert.NoError(t, err)
+	assert.Zero(t, clock.waited)
+
+	// the next request waits until the rate limit resets
+	request, _ := scriptedRequest()
+	assert.NoError(t, retrier.Do(context.Background(), request))
+	assert.Equal(t, 5*time.Second, clock.waited)
+}
+
+func TestRateLimitsPerOrganization(t *testing.T) {
+	limits := &RateLimits{}
+	assert.Same(t, limits.For("datadoghq.com/a"), limits.For("datadoghq.com/a"))
+	assert.NotSame(t, limits.For("datadoghq.com/a"), limits.For("datadoghq.com/b"))
+	assert.NotSame(t, limits.For("datadoghq.com/a"), limits.For("datadoghq.eu/a"))
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "5")
+	limits.For("datadoghq.com/a").update(clock.Now(), &http.Response{StatusCode: http.StatusOK, Header: header})
+	assert.Equal(t, 5*time.Second, limits.For("datadoghq.com/a").wait(clock.Now()))
+	assert.LessOrEqual(t, limits.For("datadoghq.com/b").wait(clock.Now()), time.Duration(0))
+}
+
+func TestRetrierStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	retrier := newTestRetrier(&fakeClock{now: time.Unix(1000, 0)})
+	request, attempts := scriptedRequest(&APIError{StatusCode: http.StatusBadGateway, Err: errors.New("502 Bad Gateway")})
+
+	assert.Error(t, retrier.Do(ctx, request))
+	assert.Len(t, *attempts, 1)
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(&APIError{StatusCode: http.StatusTooManyRequests}))
+	assert.True(t, IsTransient(&APIError{StatusCode: http.StatusGatewayTimeout}))
+	assert.True(t, IsTransient(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.False(t, IsTransient(&APIError{StatusCode: http.StatusForbidden}))
+	assert.False(t, IsTransient(context.DeadlineExceeded))
+	assert.False(t, IsTransient(errors.New("invalid query")))
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+)
+
+// SeriesFromResponse converts the series of a Datadog metrics query response
+// The tags of a series are taken from its scope and tag set
+func SeriesFromResponse(resp datadog.MetricsQueryResponse) []sli.Series {
+	series := []sli.Series{}
+	for _, s := range resp.GetSeries() {
+		tags := []string{}
+		if scope := s.GetScope(); scope != "" {
+			for _, tag := range strings.Split(scope, ",") {
+				tags = append(tags, strings.TrimSpace(tag))
+			}
+		}
+		tags = append(tags, s.GetTagSet()...)
+
+		series = append(series, sli.Series{
+			Tags:     tags,
+			Points:   s.GetPointlist(),
+			Interval: time.Duration(s.GetInterval()) * time.Second,
+		})
+	}
+	return series
+}
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+)
+
+// FormulaQuery combines named metrics queries with a formula using the Datadog v2 query API
+type FormulaQuery struct {
+	// Queries maps the names used in Formula to metrics queries
+	Queries map[string]string
+	// Formula combines the named queries, e.g. errors / requests * 100
+	Formula string
+	// Aggregator reduces every query to a single value using the scalar API, the timeseries API is used if empty
+	Aggregator string
+}
+
+type v2Query struct {
+	DataSource string `json:"data_source"`
+	Name       string `json:"name"`
+	Query      string `json:"query"`
+	Aggregator string `json:"aggregator,omitempty"`
+}
+
+type v2Formula struct {
+	Formula string `json:"formula"`
+}
+
+type v2RequestAttributes struct {
+	From     int64       `json:"from"`
+	To       int64       `json:"to"`
+	Queries  []v2Query   `json:"queries"`
+	Formulas []v2Formula `json:"formulas"`
+}
+
+type v2Request struct {
+	Data struct {
+		Type       string              `json:"type"`
+		Attributes v2RequestAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type v2TimeseriesResponse struct {
+	Data struct {
+		Attributes struct {
+			Series []struct {
+				GroupTags []string `json:"group_tags"`
+			} `json:"series"`
+			Times  []int64      `json:"times"`
+			Values [][]*float64 `json:"values"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+type v2ScalarResponse struct {
+	Data struct {
+		Attributes struct {
+			Columns []struct {
+				Name   string          `json:"name"`
+				Type   string          `json:"type"`
+				Values json.RawMessage `json:"values"`
+			} `json:"columns"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+type datadogErrorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+// QueryFormula sends q to the Datadog v2 timeseries or scalar query API.
+// The server and the API keys are taken from the configuration and context used for the v1 client.
+func QueryFormula(ctx context.Context, cfg *datadog.Configuration, from, to time.Time, q FormulaQuery) ([]sli.Series, *http.Response, error) {
+	request := v2Request{}
+	request.Data.Type = "timeseries_request"
+	if q.Aggregator != "" {
+		request.Data.Type = "scalar_request"
+	}
+	request.Data.Attributes = v2RequestAttributes{
+		From:     toMillis(from),
+		To:       toMillis(to),
+		Formulas: []v2Formula{{Formula: q.Formula}},
+	}
+
+	// sort the queries by name so that requests are deterministic
+	names := make([]string, 0, len(q.Queries))
+	for name := range q.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request.Data.Attributes.Queries = append(request.Data.Attributes.Queries, v2Query{
+			DataSource: "metrics",
+			Name:       name,
+			Query:      q.Queries[name],
+			Aggregator: q.Aggregator,
+		})
+	}
+
+	path := "/api/v2/query/timeseries"
+	if q.Aggregator != "" {
+		path = "/api/v2/query/scalar"
+	}
+
+	body, r, err := postV2(ctx, cfg, path, request)
+	if err != nil {
+		return nil, r, err
+	}
+
+	if q.Aggregator != "" {
+		series, err := parseScalarResponse(body, toMillis(from))
+		return series, r, err
+	}
+	series, err := parseTimeseriesResponse(body)
+	return series, r, err
+}
+
+func postV2(ctx context.Context, cfg *datadog.Configuration, path string, payload interface{}) ([]byte, *http.Response, error) {
+	basePath, err := cfg.ServerURLWithContext(ctx, "MetricsApiService.QueryMetrics")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, basePath+path, bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	for key, value := range cfg.DefaultHeader {
+		req.Header.Set(key, value)
+	}
+	if keys, ok := ctx.Value(datadog.ContextAPIKeys).(map[string]datadog.APIKey); ok {
+		if key, ok := keys["apiKeyAuth"]; ok {
+			req.Header.Set("DD-API-KEY", key.Key)
+		}
+		if key, ok := keys["appKeyAuth"]; ok {
+			req.Header.Set("DD-APPLICATION-KEY", key.Key)
+		}
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	r, err := httpClient.Do(req)
+	if err != nil {
+		return nil, r, err
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, r, err
+	}
+
+	if r.StatusCode >= 300 {
+		errorResponse := datadogErrorResponse{}
+		if json.Unmarshal(body, &errorResponse) == nil && len(errorResponse.Errors) > 0 {
+			return nil, r, fmt.Errorf("%s: %s", r.Status, strings.Join(errorResponse.Errors, ", "))
+		}
+		return nil, r, fmt.Errorf("%s", r.Status)
+	}
+
+	return body, r, nil
+}
+
+func parseTimeseriesResponse(body []byte) ([]sli.Series, error) {
+	response := v2TimeseriesResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("unable to parse timeseries response: %w", err)
+	}
+
+	attributes := response.Data.Attributes
+	var interval time.Duration
+	if len(attributes.Times) > 1 {
+		interval = time.Duration(attributes.Times[1]-attributes.Times[0]) * time.Millisecond
+	}
+
+	series := []sli.Series{}
+	for i, s := range attributes.Series {
+		if i >= len(attributes.Values) {
+			break
+		}
+
+		points := [][]*float64{}
+		for j, value := range attributes.Values[i] {
+			if j >= len(attributes.Times) {
+				break
+			}
+			timestamp := float64(attributes.Times[j])
+			points = append(points, []*float64{&timestamp, value})
+		}
+
+		series = append(series, sli.Series{
+			Tags:     s.GroupTags,
+			Points:   points,
+			Interval: interval,
+		})
+	}
+	return series, nil
+}
+
+// parseScalarResponse returns one series with a single point at timestamp for every row of the response
+func parseScalarResponse(body []byte, timestamp int64) ([]sli.Series, error) {
+	response := v2ScalarResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("unable to parse scalar response: %w", err)
+	}
+
+	var groups [][]string
+	var values []*float64
+	for _, column := range response.Data.Attributes.Columns {
+		var err error
+		switch column.Type {
+		case "group":
+			err = json.Unmarshal(column.Values, &groups)
+		case "number":
+			// with a formula, Datadog only returns the formula result as number column
+			err = json.Unmarshal(column.Values, &values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse scalar column '%s': %w", column.Name, err)
+		}
+	}
+
+	ts := float64(timestamp)
+	series := []sli.Series{}
+	for i, value := range values {
+		s := sli.Series{Points: [][]*float64{{&ts, value}}}
+		if i < len(groups) {
+			s.Tags = groups[i]
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
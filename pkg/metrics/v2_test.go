@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testContext points the Datadog client configuration at server
+func testContext(t *testing.T, server *httptest.Server) context.Context {
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), datadog.ContextServerIndex, 1)
+	ctx = context.WithValue(ctx, datadog.ContextServerVariables, map[string]string{"protocol": u.Scheme, "name": u.Host})
+	return context.WithValue(ctx, datadog.ContextAPIKeys, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "api-key"},
+		"appKeyAuth": {Key: "app-key"},
+	})
+}
+
+func TestQueryFormulaTimeseries(t *testing.T) {
+	var request v2Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/query/timeseries", r.URL.Path)
+		assert.Equal(t, "api-key", r.Header.Get("DD-API-KEY"))
+		assert.Equal(t, "app-key", r.Header.Get("DD-APPLICATION-KEY"))
+		body, _ := ioutil.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &request))
+
+		w.Write([]byte(`{"data":{"type":"timeseries_response","attributes":{
+			"series":[{"group_tags":["service:helloservice"],"query_index":0}],
+			"times":[1000000,1060000],
+			"values":[[2.5,null]]}}}`))
+	}))
+	defer server.Close()
+
+	series, _, err := QueryFormula(testContext(t, server), datadog.NewConfiguration(), time.Unix(1000, 0), time.Unix(1120, 0), FormulaQuery{
+		Queries: map[string]string{"requests": "sum:hits{*}", "errors": "sum:errors{*}"},
+		Formula: "errors / requests * 100",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "timeseries_request", request.Data.Type)
+	assert.Equal(t, int64(1000000), request.Data.Attributes.From)
+	assert.Equal(t, int64(1120000), request.Data.Attributes.To)
+	assert.Equal(t, []v2Formula{{Formula: "errors / requests * 100"}}, request.Data.Attributes.Formulas)
+	assert.Equal(t, []v2Query{
+		{DataSource: "metrics", Name: "errors", Query: "sum:errors{*}"},
+		{DataSource: "metrics", Name: "requests", Query: "sum:hits{*}"},
+	}, request.Data.Attributes.Queries)
+
+	require.Len(t, series, 1)
+	assert.Equal(t, []string{"service:helloservice"}, series[0].Tags)
+	assert.Equal(t, time.Minute, series[0].Interval)
+	require.Len(t, series[0].Points, 2)
+	assert.Equal(t, 2.5, *series[0].Points[0][1])
+	assert.Nil(t, series[0].Points[1][1])
+}
+
+func TestQueryFormulaScalar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/query/scalar", r.URL.Path)
+		w.Write([]byte(`{"data":{"type":"scalar_response","attributes":{"columns":[
+			{"name":"service","type":"group","values":[["service:a"],["service:b"]]},
+			{"name":"errors / requests","type":"number","values":[0.5,1.5]}]}}}`))
+	}))
+	defer server.Close()
+
+	series, _, err := QueryFormula(testContext(t, server), datadog.NewConfiguration(), time.Unix(1000, 0), time.Unix(1120, 0), FormulaQuery{
+		Queries:    map[string]string{"requests": "sum:hits{*} by {service}", "errors": "sum:errors{*} by {service}"},
+		Formula:    "errors / requests",
+		Aggregator: "sum",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, series, 2)
+	assert.Equal(t, []string{"service:b"}, series[1].Tags)
+	assert.Equal(t, 1.5, *series[1].Points[0][1])
+}
+
+func TestQueryFormulaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":["unknown query name 'foo' in formula"]}`))
+	}))
+	defer server.Close()
+
+	_, r, err := QueryFormula(testContext(t, server), datadog.NewConfiguration(), time.Unix(1000, 0), time.Unix(1120, 0), FormulaQuery{
+		Queries: map[string]string{"a": "sum:hits{*}"},
+		Formula: "foo",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown query name 'foo' in formula")
+	assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+}
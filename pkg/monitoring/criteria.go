@@ -0,0 +1,114 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
+)
+
+// ErrUnsupportedCriteria is returned for objectives whose criteria can't be expressed as Datadog thresholds
+var ErrUnsupportedCriteria = errors.New("unsupported criteria")
+
+// Criterion is an absolute criterion of a Keptn SLO, e.g. <600 or >=0.99
+type Criterion struct {
+	// Operator is one of <, <=, > and >=
+	Operator  string
+	Threshold float64
+}
+
+// ParseCriterion parses an absolute criterion. Relative criteria like <=+10% or <+50 compare the value with
+// previous evaluations, which Datadog doesn't know about, so they are rejected like criteria using =.
+func ParseCriterion(criterion string) (Criterion, error) {
+	criterion = strings.Join(strings.Fields(criterion), "")
+	for _, operator := range []string{"<=", ">=", "<", ">"} {
+		if !strings.HasPrefix(criterion, operator) {
+			continue
+		}
+
+		value := strings.TrimPrefix(criterion, operator)
+		if strings.HasSuffix(value, "%") || strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+			return Criterion{}, fmt.Errorf("%w: relative criterion '%s'", ErrUnsupportedCriteria, criterion)
+		}
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Criterion{}, fmt.Errorf("%w: invalid criterion '%s'", ErrUnsupportedCriteria, criterion)
+		}
+		return Criterion{Operator: operator, Threshold: threshold}, nil
+	}
+	return Criterion{}, fmt.Errorf("%w: criterion '%s' needs one of the operators <, <=, > or >=", ErrUnsupportedCriteria, criterion)
+}
+
+// Violation returns the operator under which the criterion is violated, e.g. >= for <
+func (c Criterion) Violation() string {
+	return map[string]string{"<": ">=", "<=": ">", ">": "<=", ">=": "<"}[c.Operator]
+}
+
+// upper checks if the criterion limits the value from above
+func (c Criterion) upper() bool {
+	return strings.HasPrefix(c.Operator, "<")
+}
+
+// Thresholds are the alert conditions derived from the pass and warning criteria of an objective:
+// the value is critical if it is Comparator Critical and a warning if it is Comparator Warning
+type Thresholds struct {
+	Comparator string
+	Critical   float64
+	// Warning is nil if the objective defines no warning criteria
+	Warning *float64
+}
+
+// ObjectiveThresholds derives the thresholds of an objective with a single absolute pass criterion and
+// an optional single absolute warning criterion. An objective fails if it neither meets its pass nor its
+// warning criterion, so the warning criterion becomes the critical threshold and the pass criterion the warning.
+func ObjectiveThresholds(objective *keptnv1.SLO) (Thresholds, error) {
+	pass, err := singleCriterion(objective.Pass)
+	if err != nil {
+		return Thresholds{}, fmt.Errorf("pass criteria: %w", err)
+	}
+	if pass == nil {
+		return Thresholds{}, fmt.Errorf("%w: the objective has no pass criteria", ErrUnsupportedCriteria)
+	}
+
+	warning, err := singleCriterion(objective.Warning)
+	if err != nil {
+		return Thresholds{}, fmt.Errorf("warning criteria: %w", err)
+	}
+	if warning == nil {
+		return Thresholds{Comparator: pass.Violation(), Critical: pass.Threshold}, nil
+	}
+
+	if warning.upper() != pass.upper() {
+		return Thresholds{}, fmt.Errorf("%w: pass and warning criteria limit the value from different sides", ErrUnsupportedCriteria)
+	}
+	if (pass.upper() && warning.Threshold < pass.Threshold) || (!pass.upper() && warning.Threshold > pass.Threshold) {
+		return Thresholds{}, fmt.Errorf("%w: the warning criterion is stricter than the pass criterion", ErrUnsupportedCriteria)
+	}
+
+	passThreshold := pass.Threshold
+	return Thresholds{Comparator: warning.Violation(), Critical: warning.Threshold, Warning: &passThreshold}, nil
+}
+
+// singleCriterion returns the only criterion of criteria, or nil if there are none
+func singleCriterion(criteria []*keptnv1.SLOCriteria) (*Criterion, error) {
+	var all []string
+	for _, c := range criteria {
+		if c != nil {
+			all = append(all, c.Criteria...)
+		}
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	if len(all) > 1 {
+		return nil, fmt.Errorf("%w: only a single criterion is supported, got %s", ErrUnsupportedCriteria, strings.Join(all, ", "))
+	}
+
+	criterion, err := ParseCriterion(all[0])
+	if err != nil {
+		return nil, err
+	}
+	return &criterion, nil
+}
@@ -0,0 +1,70 @@
+package monitoring
+
+import (
+	"testing"
+
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func objective(pass, warning []string) *keptnv1.SLO {
+	objective := &keptnv1.SLO{SLI: "response_time_p95"}
+	if pass != nil {
+		objective.Pass = []*keptnv1.SLOCriteria{{Criteria: pass}}
+	}
+	if warning != nil {
+		objective.Warning = []*keptnv1.SLOCriteria{{Criteria: warning}}
+	}
+	return objective
+}
+
+func TestParseCriterion(t *testing.T) {
+	criterion, err := ParseCriterion("<= 600")
+	require.NoError(t, err)
+	assert.Equal(t, Criterion{Operator: "<=", Threshold: 600}, criterion)
+	assert.Equal(t, ">", criterion.Violation())
+
+	criterion, err = ParseCriterion(">0.99")
+	require.NoError(t, err)
+	assert.Equal(t, Criterion{Operator: ">", Threshold: 0.99}, criterion)
+	assert.Equal(t, "<=", criterion.Violation())
+
+	for _, unsupported := range []string{"<=+10%", "<+50", ">-5", "=5", "600", "<=abc"} {
+		_, err := ParseCriterion(unsupported)
+		assert.ErrorIs(t, err, ErrUnsupportedCriteria, unsupported)
+	}
+}
+
+func TestObjectiveThresholds(t *testing.T) {
+	thresholds, err := ObjectiveThresholds(objective([]string{"<=600"}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, Thresholds{Comparator: ">", Critical: 600}, thresholds)
+
+	// the objective only fails once the warning criterion isn't met either
+	thresholds, err = ObjectiveThresholds(objective([]string{"<=600"}, []string{"<=800"}))
+	require.NoError(t, err)
+	assert.Equal(t, ">", thresholds.Comparator)
+	assert.Equal(t, 800.0, thresholds.Critical)
+	require.NotNil(t, thresholds.Warning)
+	assert.Equal(t, 600.0, *thresholds.Warning)
+
+	thresholds, err = ObjectiveThresholds(objective([]string{">=99"}, []string{">=95"}))
+	require.NoError(t, err)
+	assert.Equal(t, "<", thresholds.Comparator)
+	assert.Equal(t, 95.0, thresholds.Critical)
+}
+
+func TestObjectiveThresholdsUnsupported(t *testing.T) {
+	for name, o := range map[string]*keptnv1.SLO{
+		"no pass criteria":    objective(nil, nil),
+		"several criteria":    objective([]string{"<=600", "<=+10%"}, nil),
+		"relative criterion":  objective([]string{"<=+10%"}, nil),
+		"different sides":     objective([]string{"<=600"}, []string{">=100"}),
+		"stricter warning":    objective([]string{"<=600"}, []string{"<=500"}),
+		"unsupported warning": objective([]string{"<=600"}, []string{"<=+10%"}),
+	} {
+		_, err := ObjectiveThresholds(o)
+		assert.ErrorIs(t, err, ErrUnsupportedCriteria, name)
+	}
+}
@@ -0,0 +1,233 @@
+package monitoring
+
+import (
+	"bytes"
+	"context"
+	// embed is required for the default dashboard template
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/keptn/go-utils/pkg/api/models"
+)
+
+// DashboardFile is the Keptn resource with a custom dashboard template
+const DashboardFile = "datadog/dashboard.json"
+
+// StageVariable is the name of the dashboard template variable that selects the stage
+const StageVariable = "stage"
+
+// dashboardMarkerFormat is added to the description of the dashboard of a service, so the dashboard is found again
+// without touching other dashboards of the organization that happen to have the same title
+const dashboardMarkerFormat = "[datadog-service keptn_project:%s keptn_service:%s]"
+
+//go:embed dashboard.json
+var defaultDashboard string
+
+// DashboardIndicator is an indicator shown on the dashboard
+type DashboardIndicator struct {
+	Name string
+	// Request is the timeseries widget request that shows the indicator, the stage is selected by the template variable
+	Request map[string]interface{}
+}
+
+// DashboardData is the data the dashboard template is executed with
+type DashboardData struct {
+	Project    string
+	Service    string
+	Stages     []string
+	Indicators []DashboardIndicator
+}
+
+// NewDashboardData creates the data of the dashboard of a service with a widget per indicator. The $STAGE placeholder
+// of the queries is replaced by the value of the stage template variable.
+func NewDashboardData(project, service string, stages []string, indicators map[string]sli.Indicator) (DashboardData, error) {
+	data := DashboardData{Project: project, Service: service, Stages: stages}
+	if data.Stages == nil {
+		data.Stages = []string{}
+	}
+
+	names := make([]string, 0, len(indicators))
+	for name := range indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// widgets are shown for the time frame selected on the dashboard, so the time placeholders can't refer to a real time
+	queryContext := sli.NewQueryContext(project, "$"+StageVariable+".value", service, windowEpoch, windowEpoch.Add(time.Hour))
+	for _, name := range names {
+		request, err := widgetRequest(indicators[name], queryContext)
+		if err != nil {
+			return data, fmt.Errorf("indicator %s: %w", name, err)
+		}
+		data.Indicators = append(data.Indicators, DashboardIndicator{Name: name, Request: request})
+	}
+	return data, nil
+}
+
+// widgetRequest returns the timeseries widget request of the indicator
+func widgetRequest(indicator sli.Indicator, queryContext sli.QueryContext) (map[string]interface{}, error) {
+	rendered, err := indicator.Render(queryContext)
+	if err != nil {
+		return nil, err
+	}
+	if rendered.APIVersion != sli.APIVersionV2 {
+		return map[string]interface{}{"q": rendered.Query, "display_type": "line"}, nil
+	}
+
+	names := make([]string, 0, len(rendered.Queries))
+	for name := range rendered.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queries := []interface{}{}
+	for _, name := range names {
+		queries = append(queries, map[string]interface{}{"data_source": "metrics", "name": name, "query": rendered.Queries[name]})
+	}
+	return map[string]interface{}{
+		"queries":         queries,
+		"formulas":        []interface{}{map[string]interface{}{"formula": rendered.Formula}},
+		"response_format": "timeseries",
+		"display_type":    "line",
+	}, nil
+}
+
+// DashboardIndicators returns the indicators of configs that are not built-in defaults, the first definition of an
+// indicator wins. GetConfiguration merges the defaults underneath every sli.yaml, so indicators equal to a default are
+// left out to show the indicators of the service only; an indicator copied unchanged from the defaults can't be told
+// apart and is left out as well. If all indicators are defaults, e.g. the service has no datadog/sli.yaml, the
+// defaults are returned.
+func DashboardIndicators(configs ...*sli.Config) map[string]sli.Indicator {
+	defaults := sli.DefaultIndicators()
+	indicators := map[string]sli.Indicator{}
+	for _, config := range configs {
+		if config == nil {
+			continue
+		}
+		for name, indicator := range config.Indicators {
+			if _, ok := indicators[name]; ok {
+				continue
+			}
+			if builtin, ok := defaults[name]; ok && reflect.DeepEqual(builtin, indicator) {
+				continue
+			}
+			indicators[name] = indicator
+		}
+	}
+	if len(indicators) == 0 {
+		return defaults
+	}
+	return indicators
+}
+
+// GetDashboardTemplate reads datadog/dashboard.json of the service, stage or project, the most specific first.
+// The built-in template is returned if there is none.
+func GetDashboardTemplate(resources sli.ResourceGetter, project, stage, service string) (string, error) {
+	lookups := []func() (string, error){
+		func() (string, error) {
+			return content(resources.GetServiceResource(project, stage, service, DashboardFile))
+		},
+		func() (string, error) { return content(resources.GetStageResource(project, stage, DashboardFile)) },
+		func() (string, error) { return content(resources.GetProjectResource(project, DashboardFile)) },
+	}
+	for _, lookup := range lookups {
+		found, err := lookup()
+		if err != nil {
+			return "", fmt.Errorf("unable to read %s: %w", DashboardFile, err)
+		}
+		if found != "" {
+			return found, nil
+		}
+	}
+	return defaultDashboard, nil
+}
+
+// content returns the content of a resource, or an empty string if it doesn't exist
+func content(resource *models.Resource, err error) (string, error) {
+	if err != nil {
+		if notFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if resource == nil {
+		return "", nil
+	}
+	return resource.ResourceContent, nil
+}
+
+var dashboardFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// RenderDashboard executes the dashboard template, a Go template of the Datadog dashboard JSON, with data
+func RenderDashboard(dashboardTemplate string, data DashboardData) (*datadog.Dashboard, error) {
+	tmpl, err := template.New("dashboard").Funcs(dashboardFuncs).Option("missingkey=zero").Parse(dashboardTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dashboard template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("unable to render the dashboard template: %w", err)
+	}
+
+	dashboard := &datadog.Dashboard{}
+	if err := json.Unmarshal(rendered.Bytes(), dashboard); err != nil {
+		return nil, fmt.Errorf("the dashboard template doesn't render a valid dashboard: %w", err)
+	}
+	if dashboard.Title == "" {
+		return nil, fmt.Errorf("the dashboard template doesn't render a valid dashboard: missing title")
+	}
+	return dashboard, nil
+}
+
+// DashboardMarker identifies the dashboard of the service of a project by its description
+func DashboardMarker(project, service string) string {
+	return fmt.Sprintf(dashboardMarkerFormat, sli.TagEscape(project), sli.TagEscape(service))
+}
+
+// SyncDashboard creates the dashboard of the service of a project, or updates it if it exists already. The
+// DashboardMarker is added to the description of the dashboard and only a dashboard carrying it is updated.
+// It returns the saved dashboard and if it was created.
+func SyncDashboard(ctx context.Context, api *datadog.DashboardsApiService, project, service string, dashboard *datadog.Dashboard) (datadog.Dashboard, bool, error) {
+	marker := DashboardMarker(project, service)
+	if description := dashboard.GetDescription(); !strings.Contains(description, marker) {
+		marked := *dashboard
+		marked.SetDescription(strings.TrimSpace(description + "\n\n" + marker))
+		dashboard = &marked
+	}
+
+	existing, _, err := api.ListDashboards(ctx)
+	if err != nil {
+		return datadog.Dashboard{}, false, fmt.Errorf("unable to list the dashboards: %w", err)
+	}
+
+	for _, summary := range existing.GetDashboards() {
+		if !strings.Contains(summary.GetDescription(), marker) {
+			continue
+		}
+		updated, _, err := api.UpdateDashboard(ctx, summary.GetId(), *dashboard)
+		if err != nil {
+			return datadog.Dashboard{}, false, fmt.Errorf("unable to update the dashboard '%s': %w", dashboard.Title, err)
+		}
+		return updated, false, nil
+	}
+
+	created, _, err := api.CreateDashboard(ctx, *dashboard)
+	if err != nil {
+		return datadog.Dashboard{}, false, fmt.Errorf("unable to create the dashboard '%s': %w", dashboard.Title, err)
+	}
+	return created, true, nil
+}
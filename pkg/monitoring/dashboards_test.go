@@ -0,0 +1,127 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/datadogtest"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardIndicators(t *testing.T) {
+	defaults := sli.DefaultIndicators()
+	assert.Equal(t, defaults, DashboardIndicators(&sli.Config{Indicators: sli.DefaultIndicators()}))
+
+	hardening := sli.DefaultIndicators()
+	hardening["system_load"] = sli.Indicator{Query: "avg:system.load.1{env:$STAGE}"}
+	production := sli.DefaultIndicators()
+	production["system_load"] = sli.Indicator{Query: "max:system.load.1{env:$STAGE}"}
+	production["throughput"] = sli.Indicator{Query: "sum:requests{env:$STAGE}.as_count()"}
+
+	indicators := DashboardIndicators(&sli.Config{Indicators: hardening}, nil, &sli.Config{Indicators: production})
+	assert.Len(t, indicators, 2)
+	assert.Equal(t, "avg:system.load.1{env:$STAGE}", indicators["system_load"].Query)
+	assert.Equal(t, "sum:requests{env:$STAGE}.as_count()", indicators["throughput"].Query)
+}
+
+func TestRenderDefaultDashboard(t *testing.T) {
+	data, err := NewDashboardData("sockshop", "carts", []string{"staging", "production"}, map[string]sli.Indicator{
+		"system_load": {Query: "avg:system.load.1{env:$STAGE,service:$SERVICE}"},
+		"error_rate": {
+			APIVersion: sli.APIVersionV2,
+			Queries:    map[string]string{"requests": "sum:requests{env:$STAGE}", "errors": "sum:errors{env:$STAGE}"},
+			Formula:    "errors / requests",
+		},
+	})
+	require.NoError(t, err)
+
+	dashboard, err := RenderDashboard(defaultDashboard, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Keptn: sockshop / carts", dashboard.Title)
+
+	require.Len(t, dashboard.TemplateVariables, 1)
+	assert.Equal(t, "stage", dashboard.TemplateVariables[0].Name)
+	assert.Equal(t, []string{"staging", "production"}, dashboard.TemplateVariables[0].AvailableValues)
+	assert.Equal(t, "staging", dashboard.TemplateVariables[0].GetDefault())
+	require.Len(t, dashboard.TemplateVariablePresets, 2)
+	assert.Equal(t, "production", dashboard.TemplateVariablePresets[1].GetName())
+
+	require.Len(t, dashboard.Widgets, 2)
+	errorRate := dashboard.Widgets[0].Definition.TimeseriesWidgetDefinition
+	require.NotNil(t, errorRate)
+	assert.Equal(t, "error_rate", errorRate.GetTitle())
+	assert.Equal(t, "errors / requests", errorRate.Requests[0].GetFormulas()[0].Formula)
+	assert.Len(t, errorRate.Requests[0].GetQueries(), 2)
+
+	systemLoad := dashboard.Widgets[1].Definition.TimeseriesWidgetDefinition
+	require.NotNil(t, systemLoad)
+	assert.Equal(t, "avg:system.load.1{env:$stage.value,service:carts}", systemLoad.Requests[0].GetQ())
+}
+
+func TestRenderDashboardErrors(t *testing.T) {
+	_, err := RenderDashboard(`{"title": {{ .Project }`, DashboardData{})
+	assert.Error(t, err)
+
+	_, err = RenderDashboard(`{"title": {{ .Project }}}`, DashboardData{Project: "sockshop"})
+	assert.Error(t, err)
+
+	_, err = RenderDashboard(`{"title": "", "layout_type": "ordered", "widgets": []}`, DashboardData{})
+	assert.Error(t, err)
+}
+
+func TestGetDashboardTemplate(t *testing.T) {
+	template, err := GetDashboardTemplate(fakeResources{}, "sockshop", "staging", "carts")
+	require.NoError(t, err)
+	assert.Equal(t, defaultDashboard, template)
+
+	template, err = GetDashboardTemplate(fakeResources{projectContent: "project"}, "sockshop", "staging", "carts")
+	require.NoError(t, err)
+	assert.Equal(t, "project", template)
+
+	template, err = GetDashboardTemplate(fakeResources{content: "service", projectContent: "project"}, "sockshop", "staging", "carts")
+	require.NoError(t, err)
+	assert.Equal(t, "service", template)
+
+	_, err = GetDashboardTemplate(fakeResources{err: errors.New("connection refused")}, "sockshop", "staging", "carts")
+	assert.Error(t, err)
+}
+
+func TestSyncDashboard(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+	api := server.APIClient().DashboardsApi
+
+	data, err := NewDashboardData("sockshop", "carts", []string{"staging"}, map[string]sli.Indicator{"system_load": {Query: "avg:system.load.1{*}"}})
+	require.NoError(t, err)
+	dashboard, err := RenderDashboard(defaultDashboard, data)
+	require.NoError(t, err)
+
+	// a dashboard with the same title that wasn't created by the service is left alone
+	foreign := datadog.NewDashboard(datadog.DASHBOARDLAYOUTTYPE_ORDERED, dashboard.Title, []datadog.Widget{})
+	_, _, err = api.CreateDashboard(context.Background(), *foreign)
+	require.NoError(t, err)
+
+	created, isNew, err := SyncDashboard(context.Background(), api, "sockshop", "carts", dashboard)
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	assert.NotEmpty(t, created.GetUrl())
+	assert.Equal(t, "SLIs of the service carts in project sockshop, configured by the datadog-service\n\n[datadog-service keptn_project:sockshop keptn_service:carts]", created.GetDescription())
+
+	updated, isNew, err := SyncDashboard(context.Background(), api, "sockshop", "carts", dashboard)
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	assert.Equal(t, created.GetId(), updated.GetId())
+	assert.Len(t, server.Dashboards(), 2)
+	assert.Len(t, server.Requests(http.MethodPut, "/api/v1/dashboard/"+created.GetId()), 1)
+	assert.Len(t, server.Requests(http.MethodPut, ""), 1)
+
+	// the dashboard of a service whose name starts with the same letters is a different one
+	_, isNew, err = SyncDashboard(context.Background(), api, "sockshop", "cart", dashboard)
+	require.NoError(t, err)
+	assert.True(t, isNew)
+}
@@ -0,0 +1,101 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+)
+
+// maxEventTitleLength and maxEventTextLength are the maximum lengths of the title and the text of a Datadog event
+const (
+	maxEventTitleLength = 100
+	maxEventTextLength  = 4000
+)
+
+// LifecycleEvent is a finished Keptn task, e.g. a deployment, that is posted to the Datadog event stream
+type LifecycleEvent struct {
+	Target Target
+	// Task is the name of the finished task, e.g. deployment, release or evaluation
+	Task         string
+	KeptnContext string
+	// Version of the service, it is only tagged if it is known
+	Version string
+	// Status is the status of the task, e.g. succeeded or errored, and Result its result, e.g. pass, warning or fail
+	Status  string
+	Result  string
+	Message string
+	// Details are additional lines of the event text, e.g. the score of an evaluation
+	Details []string
+}
+
+// Tags identify the event by the target, version and Keptn context and add its result. The env, service and version
+// tags of Datadog's unified service tagging relate the event to the telemetry of the service.
+func (e LifecycleEvent) Tags() []string {
+	tags := append(e.Target.Tags(),
+		"env:"+sli.TagEscape(e.Target.Stage),
+		"service:"+sli.TagEscape(e.Target.Service),
+		"keptn_task:"+sli.TagEscape(e.Task),
+		"keptn_context:"+sli.TagEscape(e.KeptnContext),
+		"keptn_result:"+sli.TagEscape(e.Result),
+	)
+	if e.Version != "" {
+		tags = append(tags, "version:"+sli.TagEscape(e.Version))
+	}
+	return tags
+}
+
+// BuildEvent creates the Datadog event of a finished Keptn task. Events of the same Keptn context are aggregated.
+func BuildEvent(e LifecycleEvent) *datadog.EventCreateRequest {
+	title := fmt.Sprintf("Keptn %s of %s in %s (%s): %s", e.Task, e.Target.Service, e.Target.Stage, e.Target.Project, e.Result)
+	if e.Version != "" {
+		title = fmt.Sprintf("Keptn %s of %s %s in %s (%s): %s", e.Task, e.Target.Service, e.Version, e.Target.Stage, e.Target.Project, e.Result)
+	}
+	title = truncate(title, maxEventTitleLength)
+
+	lines := []string{}
+	if e.Message != "" {
+		lines = append(lines, e.Message)
+	}
+	lines = append(lines, e.Details...)
+	lines = append(lines, fmt.Sprintf("Status: %s, result: %s, Keptn context: %s", e.Status, e.Result, e.KeptnContext))
+
+	event := datadog.NewEventCreateRequest(truncate(strings.Join(lines, "\n"), maxEventTextLength), title)
+	event.SetTags(e.Tags())
+	event.SetAlertType(eventAlertType(e.Status, e.Result))
+	if e.KeptnContext != "" {
+		event.SetAggregationKey(e.KeptnContext)
+	}
+	return event
+}
+
+// eventAlertType maps the status and result of a Keptn task to the alert type of the Datadog event
+func eventAlertType(status, result string) datadog.EventAlertType {
+	switch {
+	case status == "errored" || result == "fail":
+		return datadog.EVENTALERTTYPE_ERROR
+	case result == "warning":
+		return datadog.EVENTALERTTYPE_WARNING
+	case result == "pass":
+		return datadog.EVENTALERTTYPE_SUCCESS
+	}
+	return datadog.EVENTALERTTYPE_INFO
+}
+
+// truncate shortens value to at most length characters
+func truncate(value string, length int) string {
+	if runes := []rune(value); len(runes) > length {
+		return string(runes[:length-3]) + "..."
+	}
+	return value
+}
+
+// PostEvent sends the event to the Datadog event stream
+func PostEvent(ctx context.Context, api *datadog.EventsApiService, event *datadog.EventCreateRequest) error {
+	if _, _, err := api.CreateEvent(ctx, *event); err != nil {
+		return fmt.Errorf("unable to post the event '%s': %w", event.Title, err)
+	}
+	return nil
+}
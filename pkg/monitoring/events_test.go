@@ -0,0 +1,64 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/datadogtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildEvent(t *testing.T) {
+	event := BuildEvent(LifecycleEvent{
+		Target:       Target{Project: "sockshop", Stage: "staging", Service: "carts"},
+		Task:         "evaluation",
+		KeptnContext: "da7aec34-78c4-4182-a2c8-51eb88f5871d",
+		Version:      "v0.1.1",
+		Status:       "succeeded",
+		Result:       "warning",
+		Message:      "Evaluation finished",
+		Details:      []string{"Score: 80"},
+	})
+
+	assert.Equal(t, "Keptn evaluation of carts v0.1.1 in staging (sockshop): warning", event.Title)
+	assert.Equal(t, "Evaluation finished\nScore: 80\nStatus: succeeded, result: warning, Keptn context: da7aec34-78c4-4182-a2c8-51eb88f5871d", event.Text)
+	assert.Equal(t, datadog.EVENTALERTTYPE_WARNING, event.GetAlertType())
+	assert.Equal(t, "da7aec34-78c4-4182-a2c8-51eb88f5871d", event.GetAggregationKey())
+	assert.ElementsMatch(t, []string{
+		"keptn_project:sockshop", "keptn_stage:staging", "keptn_service:carts", "env:staging", "service:carts",
+		"keptn_task:evaluation", "keptn_context:da7aec34-78c4-4182-a2c8-51eb88f5871d", "keptn_result:warning", "version:v0.1.1",
+	}, event.GetTags())
+
+	event = BuildEvent(LifecycleEvent{Target: Target{Project: "sockshop", Stage: "staging", Service: strings.Repeat("carts", 30)}, Task: "release", Status: "errored", Result: "fail"})
+	assert.Len(t, event.Title, maxEventTitleLength)
+	assert.Equal(t, datadog.EVENTALERTTYPE_ERROR, event.GetAlertType())
+	assert.False(t, event.HasAggregationKey())
+	assert.NotContains(t, strings.Join(event.GetTags(), ","), "version:")
+
+	event = BuildEvent(LifecycleEvent{Task: "evaluation", Message: strings.Repeat("x", 5000)})
+	assert.Len(t, event.Text, maxEventTextLength)
+}
+
+func TestEventAlertType(t *testing.T) {
+	assert.Equal(t, datadog.EVENTALERTTYPE_SUCCESS, eventAlertType("succeeded", "pass"))
+	assert.Equal(t, datadog.EVENTALERTTYPE_ERROR, eventAlertType("errored", "pass"))
+	assert.Equal(t, datadog.EVENTALERTTYPE_INFO, eventAlertType("succeeded", ""))
+}
+
+func TestPostEvent(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+
+	event := BuildEvent(LifecycleEvent{Target: Target{Project: "sockshop", Stage: "staging", Service: "carts"}, Task: "deployment", Status: "succeeded", Result: "pass"})
+	require.NoError(t, PostEvent(context.Background(), server.APIClient().EventsApi, event))
+	events := server.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "success", events[0]["alert_type"])
+
+	server.Script(http.MethodPost, "/api/v1/events", datadogtest.Error(http.StatusForbidden, "Forbidden"))
+	assert.Error(t, PostEvent(context.Background(), server.APIClient().EventsApi, event))
+}
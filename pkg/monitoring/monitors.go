@@ -0,0 +1,283 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
+)
+
+// SLITagKey is the tag key that identifies the objective a monitor belongs to
+const SLITagKey = "keptn_sli"
+
+// DefaultWindow is the time window monitors are evaluated over if none is configured
+const DefaultWindow = "last_5m"
+
+// windowEpoch is the start of the window that $START and $END of monitor queries are rendered for. Monitors evaluate
+// a rolling window, so the placeholders can't refer to a real time, and a fixed window keeps the query the same on
+// every sync.
+var windowEpoch = time.Unix(0, 0)
+
+// Target is the Keptn service in a stage that Datadog objects are configured for
+type Target struct {
+	Project string
+	Stage   string
+	Service string
+}
+
+// Tags identify the Datadog objects of the target
+func (t Target) Tags() []string {
+	return []string{
+		"keptn_project:" + sli.TagEscape(t.Project),
+		"keptn_stage:" + sli.TagEscape(t.Stage),
+		"keptn_service:" + sli.TagEscape(t.Service),
+	}
+}
+
+// Skipped is an objective that no Datadog object was configured for
+type Skipped struct {
+	SLI    string
+	Reason error
+}
+
+// Result summarizes the Datadog objects configured for a target
+type Result struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+	Deleted   []string
+	Skipped   []Skipped
+}
+
+// Summary describes the result in a single line, e.g. created 2, updated 1, unchanged 0
+func (r Result) Summary(kind string) string {
+	summary := fmt.Sprintf("%s: created %d, updated %d, unchanged %d", kind, len(r.Created), len(r.Updated), len(r.Unchanged))
+	if len(r.Deleted) > 0 {
+		summary += fmt.Sprintf(", deleted %d", len(r.Deleted))
+	}
+	if len(r.Skipped) > 0 {
+		skipped := make([]string, 0, len(r.Skipped))
+		for _, s := range r.Skipped {
+			skipped = append(skipped, fmt.Sprintf("%s (%v)", s.SLI, s.Reason))
+		}
+		summary += ", skipped " + strings.Join(skipped, ", ")
+	}
+	return summary
+}
+
+// BuildMonitor creates the metric monitor that alerts when the objective is violated, using the indicator's query
+func BuildMonitor(target Target, objective *keptnv1.SLO, indicator sli.Indicator, window string) (*datadog.Monitor, error) {
+	if indicator.APIVersion == sli.APIVersionV2 {
+		return nil, fmt.Errorf("%w: indicators with api_version v2 are not supported", ErrUnsupportedCriteria)
+	}
+
+	thresholds, err := ObjectiveThresholds(objective)
+	if err != nil {
+		return nil, err
+	}
+
+	if window == "" {
+		window = DefaultWindow
+	}
+	duration, err := windowDuration(window)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := indicator.Render(sli.NewQueryContext(target.Project, target.Stage, target.Service, windowEpoch, windowEpoch.Add(duration)))
+	if err != nil {
+		return nil, err
+	}
+
+	// the thresholds are defined in the unit of the SLI, the query returns the unit before the conversion
+	critical := indicator.UnitConversion.Revert(thresholds.Critical)
+	query := fmt.Sprintf("%s(%s):%s %s %s", timeAggregator(indicator.Aggregation), window, rendered.Query, thresholds.Comparator, formatFloat(critical))
+
+	options := datadog.NewMonitorOptions()
+	options.SetThresholds(datadog.MonitorThresholds{Critical: &critical})
+	if thresholds.Warning != nil {
+		warning := indicator.UnitConversion.Revert(*thresholds.Warning)
+		options.Thresholds.SetWarning(warning)
+	}
+	options.SetNotifyNoData(false)
+	options.SetIncludeTags(true)
+
+	monitor := datadog.NewMonitor(query, datadog.MONITORTYPE_QUERY_ALERT)
+	monitor.SetName(objectName(target, objective))
+	monitor.SetMessage(fmt.Sprintf("The Keptn objective %s of %s in stage %s of project %s is violated.\nPass criteria: %s",
+		objective.SLI, target.Service, target.Stage, target.Project, criteriaString(objective.Pass)))
+	monitor.SetTags(append(target.Tags(), SLITagKey+":"+sli.TagEscape(objective.SLI)))
+	monitor.SetOptions(*options)
+	return monitor, nil
+}
+
+// objectName is the name of the Datadog objects of an objective, e.g. carts (sockshop/staging): Response time P95
+func objectName(target Target, objective *keptnv1.SLO) string {
+	name := objective.DisplayName
+	if name == "" {
+		name = objective.SLI
+	}
+	return fmt.Sprintf("%s (%s/%s): %s", target.Service, target.Project, target.Stage, name)
+}
+
+// SyncMonitors creates the monitors that don't exist yet, updates the monitors that changed and deletes the monitors of
+// the target whose objective is gone. Existing monitors are found by the tags of the target and the keptn_sli tag, so
+// syncing twice is a no-op. The monitors of skipped objectives are kept, as the objectives still exist.
+func SyncMonitors(ctx context.Context, api *datadog.MonitorsApiService, target Target, monitors []*datadog.Monitor, skipped []Skipped) (Result, error) {
+	result := Result{Skipped: skipped}
+
+	bySLI, err := listMonitors(ctx, api, target)
+	if err != nil {
+		return result, err
+	}
+
+	wanted := map[string]bool{}
+	for _, s := range skipped {
+		wanted[sli.TagEscape(s.SLI)] = true
+	}
+	for _, monitor := range monitors {
+		slo := tagValue(monitor.GetTags(), SLITagKey)
+		wanted[slo] = true
+		current, ok := bySLI[slo]
+		if !ok {
+			if _, _, err := api.CreateMonitor(ctx, *monitor); err != nil {
+				return result, fmt.Errorf("unable to create the monitor for %s: %w", slo, err)
+			}
+			result.Created = append(result.Created, slo)
+			continue
+		}
+
+		if monitorEqual(current, *monitor) {
+			result.Unchanged = append(result.Unchanged, slo)
+			continue
+		}
+
+		update := datadog.NewMonitorUpdateRequest()
+		update.SetName(monitor.GetName())
+		update.SetMessage(monitor.GetMessage())
+		update.SetQuery(monitor.GetQuery())
+		update.SetType(monitor.GetType())
+		update.SetTags(monitor.GetTags())
+		update.SetOptions(monitor.GetOptions())
+		if _, _, err := api.UpdateMonitor(ctx, current.GetId(), *update); err != nil {
+			return result, fmt.Errorf("unable to update the monitor for %s: %w", slo, err)
+		}
+		result.Updated = append(result.Updated, slo)
+	}
+
+	stale := []string{}
+	for slo := range bySLI {
+		if !wanted[slo] {
+			stale = append(stale, slo)
+		}
+	}
+	for _, slo := range sorted(stale) {
+		monitor := bySLI[slo]
+		// monitors that are used by an SLO can only be deleted with force, the SLO of the objective is deleted as well
+		if _, _, err := api.DeleteMonitor(ctx, monitor.GetId(), *datadog.NewDeleteMonitorOptionalParameters().WithForce("true")); err != nil {
+			return result, fmt.Errorf("unable to delete the monitor for %s: %w", slo, err)
+		}
+		result.Deleted = append(result.Deleted, slo)
+	}
+	return result, nil
+}
+
+// MonitorIDs returns the IDs of the monitors of the target by the SLI they belong to
+func MonitorIDs(ctx context.Context, api *datadog.MonitorsApiService, target Target) (map[string]int64, error) {
+	monitors, err := listMonitors(ctx, api, target)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int64, len(monitors))
+	for slo, monitor := range monitors {
+		ids[slo] = monitor.GetId()
+	}
+	return ids, nil
+}
+
+// listMonitors returns the monitors of the target by the value of their keptn_sli tag
+func listMonitors(ctx context.Context, api *datadog.MonitorsApiService, target Target) (map[string]datadog.Monitor, error) {
+	existing, _, err := api.ListMonitors(ctx, *datadog.NewListMonitorsOptionalParameters().WithMonitorTags(strings.Join(target.Tags(), ",")))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the monitors of %s: %w", target.Service, err)
+	}
+	bySLI := map[string]datadog.Monitor{}
+	for _, monitor := range existing {
+		if slo := tagValue(monitor.GetTags(), SLITagKey); slo != "" {
+			bySLI[slo] = monitor
+		}
+	}
+	return bySLI, nil
+}
+
+// monitorEqual compares the fields of the monitors that are set by BuildMonitor
+func monitorEqual(a, b datadog.Monitor) bool {
+	aThresholds, bThresholds := a.GetOptions().Thresholds, b.GetOptions().Thresholds
+	if aThresholds == nil || bThresholds == nil {
+		return false
+	}
+	return a.GetName() == b.GetName() && a.GetMessage() == b.GetMessage() && a.GetQuery() == b.GetQuery() &&
+		a.GetType() == b.GetType() && reflect.DeepEqual(sorted(a.GetTags()), sorted(b.GetTags())) &&
+		aThresholds.GetCritical() == bThresholds.GetCritical() && aThresholds.GetWarning() == bThresholds.GetWarning()
+}
+
+func sorted(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// tagValue returns the value of the tag with the given key
+func tagValue(tags []string, key string) string {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, key+":") {
+			return strings.TrimPrefix(tag, key+":")
+		}
+	}
+	return ""
+}
+
+// timeAggregator returns the monitor time aggregation corresponding to the aggregation of the indicator
+func timeAggregator(aggregation sli.Aggregation) string {
+	switch aggregation {
+	case sli.AggregationAvg, sli.AggregationMin, sli.AggregationMax, sli.AggregationSum:
+		return string(aggregation)
+	case "", sli.AggregationLast:
+		return "last"
+	}
+	return "avg"
+}
+
+// windowDuration parses monitor windows like last_5m, last_1h or last_1d
+func windowDuration(window string) (time.Duration, error) {
+	value := strings.TrimPrefix(window, "last_")
+	if value != window && len(value) > 1 {
+		count, err := strconv.Atoi(value[:len(value)-1])
+		unit := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[value[len(value)-1]]
+		if err == nil && count > 0 && unit > 0 {
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid monitor window '%s', use e.g. last_5m, last_1h or last_1d", window)
+}
+
+func criteriaString(criteria []*keptnv1.SLOCriteria) string {
+	var all []string
+	for _, c := range criteria {
+		if c != nil {
+			all = append(all, strings.Join(c.Criteria, " and "))
+		}
+	}
+	return strings.Join(all, " or ")
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,147 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/datadogtest"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var target = Target{Project: "sockshop", Stage: "staging", Service: "carts"}
+
+func TestTargetTags(t *testing.T) {
+	assert.Equal(t, []string{"keptn_project:sockshop", "keptn_stage:staging", "keptn_service:carts"}, target.Tags())
+}
+
+func TestBuildMonitor(t *testing.T) {
+	indicator := sli.Indicator{
+		Query:          "avg:trace.http.request.duration{service:$SERVICE,env:$STAGE}",
+		Aggregation:    sli.AggregationMax,
+		UnitConversion: &sli.UnitConversion{From: "s", To: "ms"},
+	}
+	o := objective([]string{"<=600"}, []string{"<=800"})
+	o.DisplayName = "Response time P95"
+
+	monitor, err := BuildMonitor(target, o, indicator, "last_10m")
+	require.NoError(t, err)
+	assert.Equal(t, "max(last_10m):avg:trace.http.request.duration{service:carts,env:staging} > 0.8", monitor.GetQuery())
+	assert.Equal(t, "carts (sockshop/staging): Response time P95", monitor.GetName())
+	assert.Equal(t, []string{"keptn_project:sockshop", "keptn_stage:staging", "keptn_service:carts", "keptn_sli:response_time_p95"}, monitor.GetTags())
+	thresholds := monitor.GetOptions().Thresholds
+	assert.Equal(t, 0.8, thresholds.GetCritical())
+	assert.Equal(t, 0.6, thresholds.GetWarning())
+}
+
+func TestBuildMonitorRendersFixedWindow(t *testing.T) {
+	indicator := sli.Indicator{Query: "avg:system.load.1{service:$SERVICE,from:$START,to:$END}"}
+
+	monitor, err := BuildMonitor(target, objective([]string{"<=1"}, nil), indicator, "last_10m")
+	require.NoError(t, err)
+	assert.Equal(t, "last(last_10m):avg:system.load.1{service:carts,from:0,to:600} > 1", monitor.GetQuery())
+
+	// the query doesn't depend on the time of the sync
+	again, err := BuildMonitor(target, objective([]string{"<=1"}, nil), indicator, "last_10m")
+	require.NoError(t, err)
+	assert.Equal(t, monitor.GetQuery(), again.GetQuery())
+}
+
+func TestBuildMonitorUnsupported(t *testing.T) {
+	_, err := BuildMonitor(target, objective([]string{"<=600"}, nil), sli.Indicator{APIVersion: sli.APIVersionV2}, "")
+	assert.ErrorIs(t, err, ErrUnsupportedCriteria)
+
+	_, err = BuildMonitor(target, objective([]string{"<=600"}, nil), sli.Indicator{Query: "avg:system.load.1{*}"}, "5m")
+	assert.Error(t, err)
+}
+
+func TestWindowDuration(t *testing.T) {
+	duration, err := windowDuration("last_1h")
+	require.NoError(t, err)
+	assert.Equal(t, "1h0m0s", duration.String())
+
+	for _, invalid := range []string{"last_", "last_0m", "last_5x", "1h"} {
+		_, err := windowDuration(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSyncMonitors(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+	api := server.APIClient().MonitorsApi
+
+	build := func(pass string) []*datadog.Monitor {
+		monitor, err := BuildMonitor(target, objective([]string{pass}, nil), sli.Indicator{Query: "avg:system.load.1{service:$SERVICE}"}, "")
+		require.NoError(t, err)
+		return []*datadog.Monitor{monitor}
+	}
+
+	result, err := SyncMonitors(context.Background(), api, target, build("<=1"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"response_time_p95"}, result.Created)
+
+	// syncing the same monitors again changes nothing
+	result, err = SyncMonitors(context.Background(), api, target, build("<=1"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"response_time_p95"}, result.Unchanged)
+	assert.Empty(t, server.Requests(http.MethodPut, ""))
+
+	result, err = SyncMonitors(context.Background(), api, target, build("<=2"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"response_time_p95"}, result.Updated)
+	assert.Equal(t, "monitors: created 0, updated 1, unchanged 0", result.Summary("monitors"))
+
+	monitors := server.Monitors()
+	require.Len(t, monitors, 1)
+	assert.Equal(t, "last(last_5m):avg:system.load.1{service:carts} > 2", monitors[0]["query"])
+
+	// monitors of other services are left alone
+	other := Target{Project: "sockshop", Stage: "staging", Service: "orders"}
+	result, err = SyncMonitors(context.Background(), api, other, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Len(t, server.Monitors(), 1)
+}
+
+func TestSyncMonitorsDeletesStaleMonitors(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+	api := server.APIClient().MonitorsApi
+
+	build := func(name string) *datadog.Monitor {
+		o := objective([]string{"<=1"}, nil)
+		o.SLI = name
+		monitor, err := BuildMonitor(target, o, sli.Indicator{Query: "avg:system.load.1{service:$SERVICE}"}, "")
+		require.NoError(t, err)
+		return monitor
+	}
+	_, err := SyncMonitors(context.Background(), api, target, []*datadog.Monitor{build("cpu"), build("memory"), build("throughput")}, nil)
+	require.NoError(t, err)
+
+	// the monitor of a skipped objective is kept, the one of a removed objective is deleted
+	result, err := SyncMonitors(context.Background(), api, target, []*datadog.Monitor{build("cpu")}, []Skipped{{SLI: "memory", Reason: ErrUnsupportedCriteria}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu"}, result.Unchanged)
+	assert.Equal(t, []string{"throughput"}, result.Deleted)
+	assert.Equal(t, "monitors: created 0, updated 0, unchanged 1, deleted 1, skipped memory (unsupported criteria)", result.Summary("monitors"))
+
+	names := []string{}
+	for _, monitor := range server.Monitors() {
+		names = append(names, monitor["name"].(string))
+	}
+	assert.ElementsMatch(t, []string{"carts (sockshop/staging): cpu", "carts (sockshop/staging): memory"}, names)
+	assert.Equal(t, "true", server.Requests(http.MethodDelete, "")[0].Query.Get("force"))
+}
+
+func TestSyncMonitorsError(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+	server.Script(http.MethodGet, "/api/v1/monitor", datadogtest.Error(http.StatusForbidden, "Forbidden"))
+
+	_, err := SyncMonitors(context.Background(), server.APIClient().MonitorsApi, target, nil, nil)
+	assert.Error(t, err)
+}
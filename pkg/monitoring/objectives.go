@@ -0,0 +1,43 @@
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
+	"gopkg.in/yaml.v3"
+)
+
+// SLOFile is the Keptn resource with the objectives of a service
+const SLOFile = "slo.yaml"
+
+// ServiceResourceGetter reads the resources of a service from the Keptn configuration
+type ServiceResourceGetter interface {
+	GetServiceResource(project string, stage string, service string, resourceURI string) (*models.Resource, error)
+}
+
+// GetObjectives reads the slo.yaml of the target, it returns nil if the service has none
+func GetObjectives(resources ServiceResourceGetter, target Target) (*keptnv1.ServiceLevelObjectives, error) {
+	resource, err := resources.GetServiceResource(target.Project, target.Stage, target.Service, SLOFile)
+	if err != nil {
+		if notFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read %s: %w", SLOFile, err)
+	}
+	if resource == nil {
+		return nil, nil
+	}
+
+	objectives := &keptnv1.ServiceLevelObjectives{}
+	if err := yaml.Unmarshal([]byte(resource.ResourceContent), objectives); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", SLOFile, err)
+	}
+	return objectives, nil
+}
+
+// notFound checks if err is returned by the Keptn resource handler for missing resources
+func notFound(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "resource not found")
+}
@@ -0,0 +1,58 @@
+package monitoring
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResources serves content as service resource and projectContent as project resource
+type fakeResources struct {
+	content        string
+	projectContent string
+	err            error
+}
+
+func (f fakeResources) GetProjectResource(project string, resourceURI string) (*models.Resource, error) {
+	if f.projectContent == "" {
+		return nil, errors.New("Resource not found")
+	}
+	return &models.Resource{ResourceURI: &resourceURI, ResourceContent: f.projectContent}, nil
+}
+
+func (f fakeResources) GetStageResource(project string, stage string, resourceURI string) (*models.Resource, error) {
+	return nil, errors.New("Resource not found")
+}
+
+func (f fakeResources) GetServiceResource(project string, stage string, service string, resourceURI string) (*models.Resource, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.content == "" {
+		return nil, errors.New("Resource not found")
+	}
+	return &models.Resource{ResourceURI: &resourceURI, ResourceContent: f.content}, nil
+}
+
+func TestGetObjectives(t *testing.T) {
+	target := Target{Project: "sockshop", Stage: "staging", Service: "carts"}
+
+	objectives, err := GetObjectives(fakeResources{content: "spec_version: '1.0'\nobjectives:\n  - sli: response_time_p95\n    pass:\n      - criteria:\n          - '<=600'\n"}, target)
+	require.NoError(t, err)
+	require.Len(t, objectives.Objectives, 1)
+	assert.Equal(t, "response_time_p95", objectives.Objectives[0].SLI)
+	assert.Equal(t, []string{"<=600"}, objectives.Objectives[0].Pass[0].Criteria)
+
+	objectives, err = GetObjectives(fakeResources{err: errors.New("Resource not found")}, target)
+	require.NoError(t, err)
+	assert.Nil(t, objectives)
+
+	_, err = GetObjectives(fakeResources{err: errors.New("connection refused")}, target)
+	assert.Error(t, err)
+
+	_, err = GetObjectives(fakeResources{content: "objectives: {"}, target)
+	assert.Error(t, err)
+}
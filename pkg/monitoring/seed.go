@@ -0,0 +1,121 @@
+package monitoring
+
+import (
+	"bytes"
+	// embed is required for the starter resources
+	_ "embed"
+	"fmt"
+	"text/template"
+
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/keptn/go-utils/pkg/api/models"
+)
+
+// SLIFile is the Keptn resource with the Datadog indicators of a service
+const SLIFile = "datadog/sli.yaml"
+
+//go:embed starter/sli.yaml
+var starterSLI string
+
+//go:embed starter/slo.yaml
+var starterSLO string
+
+// ResourceHandler reads resources from the Keptn configuration and adds resources to a service
+type ResourceHandler interface {
+	sli.ResourceGetter
+	CreateServiceResources(project string, stage string, service string, resources []*models.Resource) (string, error)
+}
+
+// StarterResources renders the starter datadog/sli.yaml and slo.yaml of the target by their resource URI. The
+// indicators select the service by Datadog's unified service tags, env is the stage and service the Keptn service.
+func StarterResources(target Target) (map[string]string, error) {
+	resources := map[string]string{}
+	for uri, starter := range map[string]string{SLIFile: starterSLI, SLOFile: starterSLO} {
+		tmpl, err := template.New(uri).Funcs(template.FuncMap{"tagEscape": sli.TagEscape}).Parse(starter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid starter %s: %w", uri, err)
+		}
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, target); err != nil {
+			return nil, fmt.Errorf("unable to render the starter %s: %w", uri, err)
+		}
+		resources[uri] = rendered.String()
+	}
+	return resources, nil
+}
+
+// SeedResources adds the starter datadog/sli.yaml and slo.yaml to the service of the target if they are missing.
+// datadog/sli.yaml is only added if neither the project, the stage nor the service has one, existing resources are
+// never overwritten. It returns the URIs of the added resources.
+func SeedResources(resources ResourceHandler, target Target) ([]string, error) {
+	missing := []string{}
+
+	found, err := sliExists(resources, target)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		missing = append(missing, SLIFile)
+	}
+
+	found, err = exists(resources.GetServiceResource(target.Project, target.Stage, target.Service, SLOFile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", SLOFile, err)
+	}
+	if !found {
+		missing = append(missing, SLOFile)
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	starters, err := StarterResources(target)
+	if err != nil {
+		return nil, err
+	}
+	added := make([]*models.Resource, 0, len(missing))
+	for _, uri := range missing {
+		added = append(added, &models.Resource{ResourceURI: stringPtr(uri), ResourceContent: starters[uri]})
+	}
+	if _, err := resources.CreateServiceResources(target.Project, target.Stage, target.Service, added); err != nil {
+		return nil, fmt.Errorf("unable to add the starter resources to %s: %w", target.Service, err)
+	}
+	return missing, nil
+}
+
+// sliExists checks if the project, the stage or the service of the target has a datadog/sli.yaml
+func sliExists(resources sli.ResourceGetter, target Target) (bool, error) {
+	lookups := []func() (bool, error){
+		func() (bool, error) {
+			return exists(resources.GetServiceResource(target.Project, target.Stage, target.Service, SLIFile))
+		},
+		func() (bool, error) { return exists(resources.GetStageResource(target.Project, target.Stage, SLIFile)) },
+		func() (bool, error) { return exists(resources.GetProjectResource(target.Project, SLIFile)) },
+	}
+	for _, lookup := range lookups {
+		found, err := lookup()
+		if err != nil {
+			return false, fmt.Errorf("unable to read %s: %w", SLIFile, err)
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// exists checks if a resource exists, even an empty one
+func exists(resource *models.Resource, err error) (bool, error) {
+	if err != nil {
+		if notFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return resource != nil, nil
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
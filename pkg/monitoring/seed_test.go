@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/keptn/go-utils/pkg/api/models"
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeResourceHandler serves the resources of the project, stage and service by URI and records the added resources
+type fakeResourceHandler struct {
+	project, stage, service map[string]string
+	err                     error
+	created                 []*models.Resource
+}
+
+func (f *fakeResourceHandler) get(resources map[string]string, resourceURI string) (*models.Resource, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	content, ok := resources[resourceURI]
+	if !ok {
+		return nil, errors.New("Resource not found")
+	}
+	return &models.Resource{ResourceURI: &resourceURI, ResourceContent: content}, nil
+}
+
+func (f *fakeResourceHandler) GetProjectResource(project string, resourceURI string) (*models.Resource, error) {
+	return f.get(f.project, resourceURI)
+}
+
+func (f *fakeResourceHandler) GetStageResource(project string, stage string, resourceURI string) (*models.Resource, error) {
+	return f.get(f.stage, resourceURI)
+}
+
+func (f *fakeResourceHandler) GetServiceResource(project string, stage string, service string, resourceURI string) (*models.Resource, error) {
+	return f.get(f.service, resourceURI)
+}
+
+func (f *fakeResourceHandler) CreateServiceResources(project string, stage string, service string, resources []*models.Resource) (string, error) {
+	f.created = append(f.created, resources...)
+	return "1", nil
+}
+
+func TestStarterResources(t *testing.T) {
+	resources, err := StarterResources(Target{Project: "sockshop", Stage: "staging", Service: "Carts DB"})
+	require.NoError(t, err)
+
+	config, err := sli.ParseConfig([]byte(resources[SLIFile]))
+	require.NoError(t, err)
+	assert.Equal(t, "p95:trace.http.request{env:staging,service:carts_db}", config.Indicators["response_time_p95"].Query)
+	assert.Equal(t, "sum:trace.http.request.errors{env:staging,service:carts_db}.as_count()", config.Indicators["error_rate"].Queries["errors"])
+	assert.Contains(t, resources[SLIFile], "# Datadog SLIs of the service Carts DB in stage staging of project sockshop")
+
+	objectives := &keptnv1.ServiceLevelObjectives{}
+	require.NoError(t, yaml.Unmarshal([]byte(resources[SLOFile]), objectives))
+	require.Len(t, objectives.Objectives, 3)
+	for _, objective := range objectives.Objectives {
+		assert.Contains(t, config.Indicators, objective.SLI)
+	}
+	assert.Equal(t, "90%", objectives.TotalScore.Pass)
+}
+
+func TestSeedResources(t *testing.T) {
+	target := Target{Project: "sockshop", Stage: "staging", Service: "carts"}
+
+	handler := &fakeResourceHandler{}
+	added, err := SeedResources(handler, target)
+	require.NoError(t, err)
+	assert.Equal(t, []string{SLIFile, SLOFile}, added)
+	require.Len(t, handler.created, 2)
+	assert.Equal(t, SLIFile, *handler.created[0].ResourceURI)
+	assert.Contains(t, handler.created[0].ResourceContent, "service:carts")
+	assert.Equal(t, SLOFile, *handler.created[1].ResourceURI)
+
+	// an sli.yaml of the project applies to the service, so only slo.yaml is added
+	handler = &fakeResourceHandler{project: map[string]string{SLIFile: "indicators: {}"}}
+	added, err = SeedResources(handler, target)
+	require.NoError(t, err)
+	assert.Equal(t, []string{SLOFile}, added)
+
+	// existing resources are never overwritten, even empty ones
+	handler = &fakeResourceHandler{stage: map[string]string{SLIFile: ""}, service: map[string]string{SLOFile: ""}}
+	added, err = SeedResources(handler, target)
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Empty(t, handler.created)
+
+	handler = &fakeResourceHandler{err: errors.New("connection refused")}
+	_, err = SeedResources(handler, target)
+	assert.Error(t, err)
+	assert.Empty(t, handler.created)
+}
@@ -0,0 +1,216 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	keptnv1 "github.com/keptn/go-utils/pkg/lib"
+)
+
+// DefaultSLOTimeframe is the rolling time window of the Datadog SLOs if none is configured
+const DefaultSLOTimeframe = datadog.SLOTIMEFRAME_THIRTY_DAYS
+
+// SLOOptions configure the Datadog SLOs built from objectives
+type SLOOptions struct {
+	// Timeframe of the SLOs, one of 7d, 30d and 90d
+	Timeframe datadog.SLOTimeframe
+	// MonitorTarget is the percentage of time the monitor of a monitor-based SLO must not alert, e.g. 99
+	MonitorTarget float64
+}
+
+// ratioFormula matches formulas of v2 indicators that divide two queries, e.g. errors / requests * 100
+var ratioFormula = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*/\s*([A-Za-z_][A-Za-z0-9_]*)\s*(\*\s*100)?\s*$`)
+
+// BuildSLO creates the Datadog SLO of an objective. If the objective has a monitor, i.e. monitorID isn't 0, the SLO
+// is monitor-based. Otherwise, the indicator must be a v2 indicator whose formula divides two queries, e.g.
+// errors / requests * 100, then the SLO is metric-based with the ratio of good events as target.
+func BuildSLO(target Target, objective *keptnv1.SLO, indicator sli.Indicator, monitorID int64, options SLOOptions) (*datadog.ServiceLevelObjective, error) {
+	timeframe := options.Timeframe
+	if timeframe == "" {
+		timeframe = DefaultSLOTimeframe
+	}
+	if !timeframe.IsValid() || timeframe == datadog.SLOTIMEFRAME_CUSTOM {
+		return nil, fmt.Errorf("invalid SLO timeframe '%s', use 7d, 30d or 90d", timeframe)
+	}
+
+	var slo *datadog.ServiceLevelObjective
+	if monitorID != 0 {
+		if options.MonitorTarget <= 0 || options.MonitorTarget >= 100 {
+			return nil, fmt.Errorf("invalid SLO target %s, it must be between 0 and 100", formatFloat(options.MonitorTarget))
+		}
+		slo = datadog.NewServiceLevelObjective(objectName(target, objective), []datadog.SLOThreshold{*datadog.NewSLOThreshold(options.MonitorTarget, timeframe)}, datadog.SLOTYPE_MONITOR)
+		slo.SetMonitorIds([]int64{monitorID})
+	} else {
+		query, threshold, err := ratioSLO(target, objective, indicator)
+		if err != nil {
+			return nil, err
+		}
+		threshold.Timeframe = timeframe
+		slo = datadog.NewServiceLevelObjective(objectName(target, objective), []datadog.SLOThreshold{threshold}, datadog.SLOTYPE_METRIC)
+		slo.SetQuery(query)
+	}
+
+	slo.SetDescription(fmt.Sprintf("The Keptn objective %s of %s in stage %s of project %s.\nPass criteria: %s",
+		objective.SLI, target.Service, target.Stage, target.Project, criteriaString(objective.Pass)))
+	slo.SetTags(append(target.Tags(), SLITagKey+":"+sli.TagEscape(objective.SLI)))
+	return slo, nil
+}
+
+// ratioSLO returns the good and total events query and the threshold of an objective of a ratio indicator
+func ratioSLO(target Target, objective *keptnv1.SLO, indicator sli.Indicator) (datadog.ServiceLevelObjectiveQuery, datadog.SLOThreshold, error) {
+	match := ratioFormula.FindStringSubmatch(indicator.Formula)
+	if indicator.APIVersion != sli.APIVersionV2 || match == nil || indicator.QueryAggregator != "" {
+		return datadog.ServiceLevelObjectiveQuery{}, datadog.SLOThreshold{}, fmt.Errorf("%w: a metric-based SLO needs a v2 indicator whose formula divides two queries", ErrUnsupportedCriteria)
+	}
+
+	thresholds, err := ObjectiveThresholds(objective)
+	if err != nil {
+		return datadog.ServiceLevelObjectiveQuery{}, datadog.SLOThreshold{}, err
+	}
+
+	// SLOs evaluate their timeframe continuously, so the query is rendered for a fixed window like the monitor queries
+	rendered, err := indicator.Render(sli.NewQueryContext(target.Project, target.Stage, target.Service, windowEpoch, windowEpoch.Add(time.Hour)))
+	if err != nil {
+		return datadog.ServiceLevelObjectiveQuery{}, datadog.SLOThreshold{}, err
+	}
+	numerator, denominator := rendered.Queries[match[1]], rendered.Queries[match[2]]
+	if numerator == "" || denominator == "" {
+		return datadog.ServiceLevelObjectiveQuery{}, datadog.SLOThreshold{}, fmt.Errorf("the formula '%s' uses undefined queries", indicator.Formula)
+	}
+
+	// thresholds of formulas without * 100 are fractions, Datadog SLO targets are percentages
+	percentage := func(value float64) float64 {
+		value = indicator.UnitConversion.Revert(value)
+		if match[3] == "" {
+			value *= 100
+		}
+		return value
+	}
+	critical := percentage(thresholds.Critical)
+	var warning *float64
+	if thresholds.Warning != nil {
+		value := percentage(*thresholds.Warning)
+		warning = &value
+	}
+
+	// an objective with an upper limit, e.g. errors / requests <= 1, counts bad events, so the SLO counts the others
+	if thresholds.Comparator == ">" || thresholds.Comparator == ">=" {
+		numerator = fmt.Sprintf("%s - %s", denominator, numerator)
+		critical = 100 - critical
+		if warning != nil {
+			*warning = 100 - *warning
+		}
+	}
+	if critical <= 0 || critical >= 100 {
+		return datadog.ServiceLevelObjectiveQuery{}, datadog.SLOThreshold{}, fmt.Errorf("%w: the SLO target %s must be between 0 and 100", ErrUnsupportedCriteria, formatFloat(critical))
+	}
+
+	threshold := datadog.SLOThreshold{Target: critical, Warning: warning}
+	return *datadog.NewServiceLevelObjectiveQuery(denominator, numerator), threshold, nil
+}
+
+// SyncSLOs creates the SLOs that don't exist yet, updates the SLOs that changed and deletes the SLOs of the target
+// whose objective is gone. Existing SLOs are found by the tags of the target and the keptn_sli tag. The SLOs of
+// skipped objectives are kept, as the objectives still exist.
+func SyncSLOs(ctx context.Context, api *datadog.ServiceLevelObjectivesApiService, target Target, slos []*datadog.ServiceLevelObjective, skipped []Skipped) (Result, error) {
+	result := Result{Skipped: skipped}
+
+	existing, _, err := api.ListSLOs(ctx, *datadog.NewListSLOsOptionalParameters().WithTagsQuery(strings.Join(target.Tags(), " AND ")))
+	if err != nil {
+		return result, fmt.Errorf("unable to list the SLOs of %s: %w", target.Service, err)
+	}
+	bySLI := map[string]datadog.ServiceLevelObjective{}
+	for _, slo := range existing.GetData() {
+		if name := tagValue(slo.GetTags(), SLITagKey); name != "" && hasAllTags(slo.GetTags(), target.Tags()) {
+			bySLI[name] = slo
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, s := range skipped {
+		wanted[sli.TagEscape(s.SLI)] = true
+	}
+	for _, slo := range slos {
+		name := tagValue(slo.GetTags(), SLITagKey)
+		wanted[name] = true
+		current, ok := bySLI[name]
+		if !ok {
+			request := datadog.NewServiceLevelObjectiveRequest(slo.Name, slo.Thresholds, slo.Type)
+			request.Description = slo.Description
+			request.MonitorIds = slo.MonitorIds
+			request.Query = slo.Query
+			request.Tags = slo.Tags
+			if _, _, err := api.CreateSLO(ctx, *request); err != nil {
+				return result, fmt.Errorf("unable to create the SLO for %s: %w", name, err)
+			}
+			result.Created = append(result.Created, name)
+			continue
+		}
+
+		if sloEqual(current, *slo) {
+			result.Unchanged = append(result.Unchanged, name)
+			continue
+		}
+		if _, _, err := api.UpdateSLO(ctx, current.GetId(), *slo); err != nil {
+			return result, fmt.Errorf("unable to update the SLO for %s: %w", name, err)
+		}
+		result.Updated = append(result.Updated, name)
+	}
+
+	for _, name := range sorted(keys(bySLI)) {
+		if wanted[name] {
+			continue
+		}
+		stale := bySLI[name]
+		// SLOs that are shown on dashboards can only be deleted with force
+		if _, _, err := api.DeleteSLO(ctx, stale.GetId(), *datadog.NewDeleteSLOOptionalParameters().WithForce("true")); err != nil {
+			return result, fmt.Errorf("unable to delete the SLO for %s: %w", name, err)
+		}
+		result.Deleted = append(result.Deleted, name)
+	}
+	return result, nil
+}
+
+// sloEqual compares the fields of the SLOs that are set by BuildSLO
+func sloEqual(a, b datadog.ServiceLevelObjective) bool {
+	if a.Name != b.Name || a.GetDescription() != b.GetDescription() || a.Type != b.Type || len(a.Thresholds) != len(b.Thresholds) ||
+		!reflect.DeepEqual(a.GetQuery(), b.GetQuery()) || !reflect.DeepEqual(a.GetMonitorIds(), b.GetMonitorIds()) ||
+		!reflect.DeepEqual(sorted(a.GetTags()), sorted(b.GetTags())) {
+		return false
+	}
+	for i := range a.Thresholds {
+		if a.Thresholds[i].Target != b.Thresholds[i].Target || a.Thresholds[i].Timeframe != b.Thresholds[i].Timeframe ||
+			a.Thresholds[i].GetWarning() != b.Thresholds[i].GetWarning() {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAllTags checks if tags contain all of required, the tags query of Datadog is a search that may match more SLOs
+func hasAllTags(tags, required []string) bool {
+	for _, tag := range required {
+		found := false
+		for _, t := range tags {
+			found = found || t == tag
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func keys(m map[string]datadog.ServiceLevelObjective) []string {
+	all := make([]string, 0, len(m))
+	for key := range m {
+		all = append(all, key)
+	}
+	return all
+}
@@ -0,0 +1,129 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/api/v1/datadog"
+	"github.com/keptn-sandbox/datadog-service/pkg/datadogtest"
+	"github.com/keptn-sandbox/datadog-service/pkg/sli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errorRate = sli.Indicator{
+	APIVersion: sli.APIVersionV2,
+	Queries: map[string]string{
+		"errors":   "sum:trace.http.request.errors{service:$SERVICE,env:$STAGE}.as_count()",
+		"requests": "sum:trace.http.request.hits{service:$SERVICE,env:$STAGE}.as_count()",
+	},
+	Formula: "errors / requests * 100",
+}
+
+func TestBuildMetricSLO(t *testing.T) {
+	slo, err := BuildSLO(target, objective([]string{"<=1"}, []string{"<=2"}), errorRate, 0, SLOOptions{Timeframe: datadog.SLOTIMEFRAME_SEVEN_DAYS})
+	require.NoError(t, err)
+	assert.Equal(t, datadog.SLOTYPE_METRIC, slo.Type)
+	assert.Equal(t, "sum:trace.http.request.hits{service:carts,env:staging}.as_count() - sum:trace.http.request.errors{service:carts,env:staging}.as_count()", slo.GetQuery().Numerator)
+	assert.Equal(t, "sum:trace.http.request.hits{service:carts,env:staging}.as_count()", slo.GetQuery().Denominator)
+	require.Len(t, slo.Thresholds, 1)
+	assert.Equal(t, 98.0, slo.Thresholds[0].Target)
+	assert.Equal(t, 99.0, slo.Thresholds[0].GetWarning())
+	assert.Equal(t, datadog.SLOTIMEFRAME_SEVEN_DAYS, slo.Thresholds[0].Timeframe)
+	assert.Contains(t, slo.GetTags(), "keptn_sli:response_time_p95")
+
+	// a ratio of good events without * 100 is a fraction
+	successRate := errorRate
+	successRate.Formula = "requests / errors"
+	slo, err = BuildSLO(target, objective([]string{">=0.995"}, nil), successRate, 0, SLOOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "sum:trace.http.request.hits{service:carts,env:staging}.as_count()", slo.GetQuery().Numerator)
+	assert.InDelta(t, 99.5, slo.Thresholds[0].Target, 1e-9)
+	assert.Equal(t, DefaultSLOTimeframe, slo.Thresholds[0].Timeframe)
+}
+
+func TestBuildMonitorSLO(t *testing.T) {
+	slo, err := BuildSLO(target, objective([]string{"<=600"}, nil), sli.Indicator{Query: "avg:trace.http.request.duration{*}"}, 42, SLOOptions{MonitorTarget: 99.9})
+	require.NoError(t, err)
+	assert.Equal(t, datadog.SLOTYPE_MONITOR, slo.Type)
+	assert.Equal(t, []int64{42}, slo.GetMonitorIds())
+	assert.Equal(t, 99.9, slo.Thresholds[0].Target)
+
+	_, err = BuildSLO(target, objective([]string{"<=600"}, nil), sli.Indicator{}, 42, SLOOptions{MonitorTarget: 100})
+	assert.Error(t, err)
+}
+
+func TestBuildSLOUnsupported(t *testing.T) {
+	_, err := BuildSLO(target, objective([]string{"<=600"}, nil), sli.Indicator{Query: "avg:trace.http.request.duration{*}"}, 0, SLOOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedCriteria)
+
+	product := errorRate
+	product.Formula = "errors * requests"
+	_, err = BuildSLO(target, objective([]string{"<=1"}, nil), product, 0, SLOOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedCriteria)
+
+	_, err = BuildSLO(target, objective([]string{"<=1"}, nil), errorRate, 0, SLOOptions{Timeframe: "1d"})
+	assert.Error(t, err)
+}
+
+func TestSyncSLOs(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+	api := server.APIClient().ServiceLevelObjectivesApi
+
+	build := func(pass string, sli string) *datadog.ServiceLevelObjective {
+		o := objective([]string{pass}, nil)
+		o.SLI = sli
+		slo, err := BuildSLO(target, o, errorRate, 0, SLOOptions{})
+		require.NoError(t, err)
+		return slo
+	}
+
+	result, err := SyncSLOs(context.Background(), api, target, []*datadog.ServiceLevelObjective{build("<=1", "error_rate"), build("<=5", "error_rate_max")}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error_rate", "error_rate_max"}, result.Created)
+
+	// syncing the same SLOs again changes nothing
+	result, err = SyncSLOs(context.Background(), api, target, []*datadog.ServiceLevelObjective{build("<=1", "error_rate"), build("<=5", "error_rate_max")}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error_rate", "error_rate_max"}, result.Unchanged)
+
+	// SLOs of other services are left alone
+	other := Target{Project: "sockshop", Stage: "staging", Service: "orders"}
+	_, err = SyncSLOs(context.Background(), api, other, []*datadog.ServiceLevelObjective{}, nil)
+	require.NoError(t, err)
+
+	result, err = SyncSLOs(context.Background(), api, target, []*datadog.ServiceLevelObjective{build("<=2", "error_rate")}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error_rate"}, result.Updated)
+	assert.Equal(t, []string{"error_rate_max"}, result.Deleted)
+	assert.Equal(t, "true", server.Requests(http.MethodDelete, "")[0].Query.Get("force"))
+
+	slos := server.SLOs()
+	require.Len(t, slos, 1)
+	assert.Equal(t, 98.0, slos[0]["thresholds"].([]interface{})[0].(map[string]interface{})["target"])
+
+	// the SLO of an objective that is skipped, e.g. because its indicator is missing for now, is kept
+	result, err = SyncSLOs(context.Background(), api, target, nil, []Skipped{{SLI: "error_rate", Reason: errors.New("not defined in datadog/sli.yaml")}})
+	require.NoError(t, err)
+	assert.Empty(t, result.Deleted)
+	assert.Equal(t, "SLOs: created 0, updated 0, unchanged 0, skipped error_rate (not defined in datadog/sli.yaml)", result.Summary("SLOs"))
+	assert.Len(t, server.SLOs(), 1)
+}
+
+func TestMonitorIDs(t *testing.T) {
+	server := datadogtest.NewServer()
+	defer server.Close()
+	api := server.APIClient().MonitorsApi
+
+	monitor, err := BuildMonitor(target, objective([]string{"<=600"}, nil), sli.Indicator{Query: "avg:system.load.1{*}"}, "")
+	require.NoError(t, err)
+	created, _, err := api.CreateMonitor(context.Background(), *monitor)
+	require.NoError(t, err)
+
+	ids, err := MonitorIDs(context.Background(), api, target)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"response_time_p95": created.GetId()}, ids)
+}
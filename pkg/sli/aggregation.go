@@ -0,0 +1,134 @@
+package sli
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aggregation defines how the point list of a series is reduced to a single SLI value
+type Aggregation string
+
+const (
+	AggregationLast   Aggregation = "last"
+	AggregationFirst  Aggregation = "first"
+	AggregationAvg    Aggregation = "avg"
+	AggregationMin    Aggregation = "min"
+	AggregationMax    Aggregation = "max"
+	AggregationSum    Aggregation = "sum"
+	AggregationCount  Aggregation = "count"
+	AggregationMedian Aggregation = "median"
+)
+
+// DefaultAggregation is used for indicators that don't define an aggregation
+const DefaultAggregation = AggregationLast
+
+// ErrNoDataPoints is returned when a point list doesn't contain a single value
+var ErrNoDataPoints = errors.New("no data points")
+
+// Validate checks if a is a known aggregation or a percentile like p95 or p99.9
+func (a Aggregation) Validate() error {
+	switch a {
+	case "", AggregationLast, AggregationFirst, AggregationAvg, AggregationMin, AggregationMax,
+		AggregationSum, AggregationCount, AggregationMedian:
+		return nil
+	}
+
+	if _, ok := a.percentile(); ok {
+		return nil
+	}
+	return fmt.Errorf("unknown aggregation '%s'", a)
+}
+
+// Apply reduces points to a single value. Every point is a [timestamp, value] pair as returned by Datadog.
+// Points without a value are skipped.
+func (a Aggregation) Apply(points [][]*float64) (float64, error) {
+	values := pointValues(points)
+	if len(values) == 0 {
+		return 0, ErrNoDataPoints
+	}
+
+	switch a {
+	case "", AggregationLast:
+		return values[len(values)-1], nil
+	case AggregationFirst:
+		return values[0], nil
+	case AggregationAvg:
+		return sum(values) / float64(len(values)), nil
+	case AggregationMin:
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min, nil
+	case AggregationMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	case AggregationSum:
+		return sum(values), nil
+	case AggregationCount:
+		return float64(len(values)), nil
+	case AggregationMedian:
+		return percentile(values, 50), nil
+	}
+
+	if p, ok := a.percentile(); ok {
+		return percentile(values, p), nil
+	}
+	return 0, fmt.Errorf("unknown aggregation '%s'", a)
+}
+
+// percentile parses aggregations like p90 or p99.9
+func (a Aggregation) percentile() (float64, bool) {
+	s := string(a)
+	if !strings.HasPrefix(s, "p") {
+		return 0, false
+	}
+
+	p, err := strconv.ParseFloat(s[1:], 64)
+	// NaN passes both range checks, and a NaN rank can't index the sorted values
+	if err != nil || math.IsNaN(p) || math.IsInf(p, 0) || p < 0 || p > 100 {
+		return 0, false
+	}
+	return p, true
+}
+
+// pointValues returns the values of points in order, skipping points without a value
+func pointValues(points [][]*float64) []float64 {
+	values := make([]float64, 0, len(points))
+	for _, point := range points {
+		if len(point) < 2 || point[1] == nil {
+			continue
+		}
+		values = append(values, *point[1])
+	}
+	return values
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// percentile computes the p-th percentile of values using linear interpolation between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,70 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// points builds a Datadog point list from values, nil values are kept as points without a value
+func points(values ...*float64) [][]*float64 {
+	list := [][]*float64{}
+	for i, v := range values {
+		ts := float64(i * 1000)
+		list = append(list, []*float64{&ts, v})
+	}
+	return list
+}
+
+func value(v float64) *float64 {
+	return &v
+}
+
+func TestAggregationApply(t *testing.T) {
+	list := points(value(4), nil, value(1), value(3), value(2), nil)
+
+	tests := []struct {
+		aggregation Aggregation
+		want        float64
+	}{
+		{aggregation: "", want: 2},
+		{aggregation: AggregationLast, want: 2},
+		{aggregation: AggregationFirst, want: 4},
+		{aggregation: AggregationAvg, want: 2.5},
+		{aggregation: AggregationMin, want: 1},
+		{aggregation: AggregationMax, want: 4},
+		{aggregation: AggregationSum, want: 10},
+		{aggregation: AggregationCount, want: 4},
+		{aggregation: AggregationMedian, want: 2.5},
+		{aggregation: "p0", want: 1},
+		{aggregation: "p100", want: 4},
+		{aggregation: "p90", want: 3.7},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.aggregation), func(t *testing.T) {
+			got, err := tt.aggregation.Apply(list)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestAggregationApplyWithoutValues(t *testing.T) {
+	_, err := AggregationAvg.Apply(points(nil, nil))
+	assert.ErrorIs(t, err, ErrNoDataPoints)
+
+	_, err = AggregationLast.Apply(nil)
+	assert.ErrorIs(t, err, ErrNoDataPoints)
+}
+
+func TestAggregationValidate(t *testing.T) {
+	for _, valid := range []Aggregation{"", "last", "median", "p95", "p99.9"} {
+		assert.NoError(t, valid.Validate(), string(valid))
+	}
+
+	for _, invalid := range []Aggregation{"mean", "p", "p101", "pxx", "P95", "pNaN", "pnan", "pInf", "p-Inf"} {
+		assert.Error(t, invalid.Validate(), string(invalid))
+	}
+}
@@ -0,0 +1,86 @@
+package sli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// BaselineSuffix is appended to the indicator name for the SLI with the baseline value
+	BaselineSuffix = "_baseline"
+	// ChangeSuffix is appended to the indicator name for the SLI with the relative change to the baseline in percent
+	ChangeSuffix = "_change"
+)
+
+// BaselinePrevious compares the evaluation window with the window of the same length right before it
+const BaselinePrevious Baseline = "previous"
+
+// ErrZeroBaseline is returned by RelativeChange if the baseline value is 0
+var ErrZeroBaseline = errors.New("relative change is undefined for a baseline value of 0")
+
+// Baseline defines the window an indicator is compared with. It is either previous or the
+// time the evaluation window is shifted back, as Go duration (e.g. 12h) or in days (1d) or weeks (1w).
+type Baseline string
+
+// Validate checks if b is previous or a positive shift
+func (b Baseline) Validate() error {
+	if b == "" || b == BaselinePrevious {
+		return nil
+	}
+
+	shift, err := b.shift()
+	if err != nil || shift <= 0 {
+		return fmt.Errorf("invalid baseline '%s', use previous or a positive duration like 24h, 1d or 1w", b)
+	}
+	return nil
+}
+
+// Window returns the baseline window for the evaluation window between start and end
+func (b Baseline) Window(start, end time.Time) (time.Time, time.Time) {
+	if b == BaselinePrevious {
+		return start.Add(-end.Sub(start)), start
+	}
+
+	shift, _ := b.shift()
+	return start.Add(-shift), end.Add(-shift)
+}
+
+func (b Baseline) shift() (time.Duration, error) {
+	value := string(b)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(value, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// RelativeChange returns the change of current compared to baseline in percent
+func RelativeChange(current, baseline float64) (float64, error) {
+	if baseline == 0 {
+		return 0, ErrZeroBaseline
+	}
+	return (current - baseline) / baseline * 100, nil
+}
+
+// BaselineIndicator returns the name and the configuration of the indicator a baseline or change SLI
+// like response_time_baseline or response_time_change is computed from
+func BaselineIndicator(indicators map[string]Indicator, name string) (string, Indicator, bool) {
+	for _, suffix := range []string{BaselineSuffix, ChangeSuffix} {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		indicator, ok := indicators[strings.TrimSuffix(name, suffix)]
+		if ok && indicator.Baseline != "" {
+			return strings.TrimSuffix(name, suffix), indicator, true
+		}
+	}
+	return "", Indicator{}, false
+}
@@ -0,0 +1,71 @@
+package sli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaselineValidate(t *testing.T) {
+	for _, baseline := range []Baseline{"", BaselinePrevious, "12h", "1d", "2w", "90m"} {
+		assert.NoError(t, baseline.Validate(), string(baseline))
+	}
+
+	for _, baseline := range []Baseline{"yesterday", "0d", "-1h", "1.5d"} {
+		assert.EqualError(t, baseline.Validate(), "invalid baseline '"+string(baseline)+"', use previous or a positive duration like 24h, 1d or 1w")
+	}
+}
+
+func TestBaselineWindow(t *testing.T) {
+	start := time.Date(2021, 1, 15, 15, 4, 45, 0, time.UTC)
+	end := start.Add(5 * time.Minute)
+
+	baselineStart, baselineEnd := BaselinePrevious.Window(start, end)
+	assert.Equal(t, start.Add(-5*time.Minute), baselineStart)
+	assert.Equal(t, start, baselineEnd)
+
+	baselineStart, baselineEnd = Baseline("1w").Window(start, end)
+	assert.Equal(t, time.Date(2021, 1, 8, 15, 4, 45, 0, time.UTC), baselineStart)
+	assert.Equal(t, time.Date(2021, 1, 8, 15, 9, 45, 0, time.UTC), baselineEnd)
+
+	baselineStart, _ = Baseline("1d").Window(start, end)
+	assert.Equal(t, time.Date(2021, 1, 14, 15, 4, 45, 0, time.UTC), baselineStart)
+
+	baselineStart, _ = Baseline("30m").Window(start, end)
+	assert.Equal(t, start.Add(-30*time.Minute), baselineStart)
+}
+
+func TestRelativeChange(t *testing.T) {
+	change, err := RelativeChange(150, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, change)
+
+	change, err = RelativeChange(75, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, -25.0, change)
+
+	_, err = RelativeChange(1, 0)
+	assert.ErrorIs(t, err, ErrZeroBaseline)
+}
+
+func TestBaselineIndicator(t *testing.T) {
+	indicators := map[string]Indicator{
+		"response_time": {Query: "avg:trace.http.request.duration{*}", Baseline: "1w"},
+		"cpu":           {Query: "avg:cpu{*}"},
+	}
+
+	name, indicator, ok := BaselineIndicator(indicators, "response_time_change")
+	assert.True(t, ok)
+	assert.Equal(t, "response_time", name)
+	assert.Equal(t, indicators["response_time"], indicator)
+
+	name, _, ok = BaselineIndicator(indicators, "response_time_baseline")
+	assert.True(t, ok)
+	assert.Equal(t, "response_time", name)
+
+	_, _, ok = BaselineIndicator(indicators, "cpu_change")
+	assert.False(t, ok)
+	_, _, ok = BaselineIndicator(indicators, "memory_baseline")
+	assert.False(t, ok)
+}
@@ -0,0 +1,340 @@
+package sli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported versions of the datadog/sli.yaml format
+// 1.0 only supports plain query strings, 2.0 additionally supports indicators with options
+const (
+	SpecVersion1 = "1.0"
+	SpecVersion2 = "2.0"
+)
+
+// API versions of the Datadog query API
+const (
+	APIVersionV1 = "v1"
+	APIVersionV2 = "v2"
+)
+
+// Indicator is the definition of a single SLI in datadog/sli.yaml
+// An indicator is either written as a plain query string or, with spec_version 2.0, as a mapping, e.g.:
+//
+//	spec_version: '2.0'
+//	indicators:
+//	  throughput: sum:trace.http.request.hits{service:$SERVICE}.as_count()
+//	  response_time_p95:
+//	    query: avg:trace.http.request.duration{service:$SERVICE}
+//	    aggregation: p95
+//	    unit_conversion:
+//	      from: s
+//	      to: ms
+//	  error_rate:
+//	    api_version: v2
+//	    queries:
+//	      errors: sum:trace.http.request.errors{service:$SERVICE}.as_count()
+//	      requests: sum:trace.http.request.hits{service:$SERVICE}.as_count()
+//	    formula: errors / requests * 100
+//	    query_aggregator: sum
+//	    missing_data: zero
+type Indicator struct {
+	// Query is the Datadog metrics query of a v1 indicator
+	Query string `yaml:"query"`
+	// APIVersion selects the Datadog query API, either v1 (default) or v2
+	APIVersion string `yaml:"api_version"`
+	// Queries maps names to the metrics queries of a v2 indicator
+	Queries map[string]string `yaml:"queries"`
+	// Formula combines the named queries of a v2 indicator, it defaults to the name of a single query
+	Formula string `yaml:"formula"`
+	// QueryAggregator reduces every named query to a single value using the v2 scalar API, e.g. sum
+	// If empty, the v2 timeseries API is used
+	QueryAggregator string `yaml:"query_aggregator"`
+	// Aggregation defines how the point list of a series is reduced to a single value
+	Aggregation Aggregation `yaml:"aggregation"`
+	// SeriesAggregation defines how the values of several series returned by a grouped query are combined
+	SeriesAggregation SeriesAggregation `yaml:"series_aggregation"`
+	// Scope selects the series with the given comma separated tags, e.g. service:$SERVICE
+	Scope string `yaml:"scope"`
+	// Timeout limits the time spent on querying the indicator, including waiting for the data, e.g. 30s
+	Timeout time.Duration `yaml:"timeout"`
+	// MissingData is the policy for queries without data, one of skip, zero, fail or a fixed value
+	MissingData MissingDataPolicy `yaml:"missing_data"`
+	// UnitConversion converts the SLI value from one unit to another
+	UnitConversion *UnitConversion `yaml:"unit_conversion"`
+	// FallbackQuery is a v1 query that is used if the indicator query fails or returns no data
+	FallbackQuery string `yaml:"fallback_query"`
+	// InjectFilters adds the custom filters of the SLO as tags to every {...} scope of the queries
+	InjectFilters bool `yaml:"inject_filters"`
+	// Baseline additionally evaluates the indicator over a shifted window, e.g. previous or 1w,
+	// and reports the baseline value and the relative change as <name>_baseline and <name>_change
+	Baseline Baseline `yaml:"baseline"`
+}
+
+// UnmarshalYAML allows an indicator to be defined as a plain query string and rejects unknown options
+func (i *Indicator) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		i.Query = value.Value
+		return nil
+	}
+
+	type plain Indicator
+	if err := checkKnownFields(value, plain{}); err != nil {
+		return err
+	}
+	return value.Decode((*plain)(i))
+}
+
+// Config represents the content of a datadog/sli.yaml file
+type Config struct {
+	SpecVersion string               `yaml:"spec_version"`
+	Indicators  map[string]Indicator `yaml:"indicators"`
+	// Timeout limits the time spent on a get-sli event, it overrides the service-wide timeout
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ParseConfig parses and validates the content of a datadog/sli.yaml file
+func ParseConfig(content []byte) (*Config, error) {
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("missing required field: indicators")
+	}
+	document := root.Content[0]
+
+	config := &Config{}
+	switch specVersion(document) {
+	case "", SpecVersion1:
+		// the flat format of Keptn SLI files: indicator names mapped to query strings
+		flat := struct {
+			SpecVersion string               `yaml:"spec_version"`
+			Indicators  map[string]yaml.Node `yaml:"indicators"`
+		}{}
+		if err := document.Decode(&flat); err != nil {
+			return nil, err
+		}
+
+		config.SpecVersion = flat.SpecVersion
+		config.Indicators = make(map[string]Indicator, len(flat.Indicators))
+		for name, node := range flat.Indicators {
+			if node.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("indicator '%s' (line %d): indicator options require spec_version '%s'", name, node.Line, SpecVersion2)
+			}
+			config.Indicators[name] = Indicator{Query: node.Value}
+		}
+	case SpecVersion2:
+		if err := checkKnownFields(document, Config{}); err != nil {
+			return nil, err
+		}
+		if err := document.Decode(config); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spec_version '%s', supported versions are '%s' and '%s'", specVersion(document), SpecVersion1, SpecVersion2)
+	}
+
+	if len(config.Indicators) == 0 && config.Timeout == 0 {
+		return nil, errors.New("missing required field: indicators")
+	}
+	if config.Timeout < 0 {
+		return nil, fmt.Errorf("negative timeout '%v'", config.Timeout)
+	}
+
+	for name, indicator := range config.Indicators {
+		if err := indicator.validate(); err != nil {
+			return nil, fmt.Errorf("indicator '%s': %w", name, err)
+		}
+		config.Indicators[name] = indicator
+	}
+
+	return config, nil
+}
+
+// specVersion returns the value of the spec_version key of a mapping node
+func specVersion(document *yaml.Node) string {
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		if document.Content[i].Value == "spec_version" {
+			return document.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// checkKnownFields returns an error listing all keys of the mapping node that are no yaml fields of v
+func checkKnownFields(node *yaml.Node, v interface{}) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	known := []string{}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		known = append(known, strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0])
+	}
+
+	unknown := []string{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !contains(known, key.Value) {
+			unknown = append(unknown, fmt.Sprintf("'%s' (line %d)", key.Value, key.Line))
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown keys %s, allowed keys are %s", strings.Join(unknown, ", "), strings.Join(known, ", "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Fallback returns the indicator that queries the fallback query with the same options
+func (i Indicator) Fallback() Indicator {
+	fallback := i
+	fallback.Query = i.FallbackQuery
+	fallback.APIVersion = APIVersionV1
+	fallback.Queries = nil
+	fallback.Formula = ""
+	fallback.QueryAggregator = ""
+	fallback.FallbackQuery = ""
+	return fallback
+}
+
+// validate checks the indicator definition and fills in the default formula of v2 indicators
+func (i *Indicator) validate() error {
+	switch i.APIVersion {
+	case "", APIVersionV1:
+		if i.Query == "" {
+			return errors.New("missing required field: query")
+		}
+		if len(i.Queries) != 0 || i.Formula != "" || i.QueryAggregator != "" {
+			return errors.New("queries, formula and query_aggregator require api_version v2")
+		}
+	case APIVersionV2:
+		if len(i.Queries) == 0 {
+			return errors.New("missing required field: queries")
+		}
+		if i.Query != "" {
+			return errors.New("api_version v2 uses queries instead of query")
+		}
+		if i.Formula == "" {
+			if len(i.Queries) > 1 {
+				return errors.New("missing required field: formula")
+			}
+			for name := range i.Queries {
+				i.Formula = name
+			}
+		}
+		if err := validateQueryAggregator(i.QueryAggregator); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown api_version '%s'", i.APIVersion)
+	}
+
+	if err := i.MissingData.Validate(); err != nil {
+		return err
+	}
+
+	if err := i.Baseline.Validate(); err != nil {
+		return err
+	}
+
+	if i.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+
+	if i.UnitConversion != nil {
+		if _, err := i.UnitConversion.factor(); err != nil {
+			return err
+		}
+	}
+
+	if err := i.Aggregation.Validate(); err != nil {
+		return err
+	}
+	return i.SeriesAggregation.Validate()
+}
+
+// validateQueryAggregator checks the aggregators supported by the Datadog v2 scalar API
+func validateQueryAggregator(aggregator string) error {
+	switch aggregator {
+	case "", "avg", "min", "max", "sum", "last", "mean", "area", "l2norm", "percentile":
+		return nil
+	}
+	return fmt.Errorf("unknown query_aggregator '%s'", aggregator)
+}
+
+// ResourceGetter retrieves resources from the Keptn configuration repo
+type ResourceGetter interface {
+	GetProjectResource(project string, resourceURI string) (*models.Resource, error)
+	GetStageResource(project string, stage string, resourceURI string) (*models.Resource, error)
+	GetServiceResource(project string, stage string, service string, resourceURI string) (*models.Resource, error)
+}
+
+// GetConfiguration retrieves the SLI configuration for a service considering SLI configuration on project and stage level.
+// Indicators and timeout on service level override the ones on stage level, which override the ones on project level,
+// which override the built-in DefaultIndicators.
+func GetConfiguration(resources ResourceGetter, project, stage, service, resourceURI string) (*Config, error) {
+	merged := &Config{Indicators: DefaultIndicators()}
+
+	addResource := func(res *models.Resource, err error) error {
+		if err != nil {
+			// return error except "resource not found" type
+			if !strings.Contains(strings.ToLower(err.Error()), "resource not found") {
+				return err
+			}
+			return nil
+		}
+		if res == nil {
+			return nil
+		}
+
+		config, err := ParseConfig([]byte(res.ResourceContent))
+		if err != nil {
+			return err
+		}
+		for name, indicator := range config.Indicators {
+			merged.Indicators[name] = indicator
+		}
+		if config.Timeout > 0 {
+			merged.Timeout = config.Timeout
+		}
+		return nil
+	}
+
+	if project != "" {
+		if err := addResource(resources.GetProjectResource(project, resourceURI)); err != nil {
+			return nil, err
+		}
+	}
+
+	if project != "" && stage != "" {
+		if err := addResource(resources.GetStageResource(project, stage, resourceURI)); err != nil {
+			return nil, err
+		}
+	}
+
+	if project != "" && stage != "" && service != "" {
+		if err := addResource(resources.GetServiceResource(project, stage, service, resourceURI)); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
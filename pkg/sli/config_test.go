@@ -0,0 +1,188 @@
+package sli
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigFlatFormat(t *testing.T) {
+	config, err := ParseConfig([]byte(`---
+spec_version: '1.0'
+indicators:
+  http_response_time: avg:network.http.response_time{*} by {$SERVICE}.rollup(avg, $DURATION)
+  system_load: avg:system.load.1{*}
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.0", config.SpecVersion)
+	assert.Equal(t, map[string]Indicator{
+		"http_response_time": {Query: "avg:network.http.response_time{*} by {$SERVICE}.rollup(avg, $DURATION)"},
+		"system_load":        {Query: "avg:system.load.1{*}"},
+	}, config.Indicators)
+
+	_, err = ParseConfig([]byte("indicators:\n  cpu:\n    query: avg:cpu{*}\n"))
+	assert.EqualError(t, err, "indicator 'cpu' (line 3): indicator options require spec_version '2.0'")
+}
+
+func TestParseConfigWithOptions(t *testing.T) {
+	config, err := ParseConfig([]byte(`---
+spec_version: '2.0'
+indicators:
+  http_response_time: avg:network.http.response_time{*} by {$SERVICE}.rollup(avg, $DURATION)
+  cpu_p95:
+    query: avg:kubernetes.cpu.usage.total{service:$SERVICE}
+    aggregation: p95
+    timeout: 30s
+    missing_data: fail
+    unit_conversion:
+      from: ns
+      to: ms
+    fallback_query: avg:container.cpu.usage{service:$SERVICE}
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "2.0", config.SpecVersion)
+	assert.Equal(t, Indicator{Query: "avg:network.http.response_time{*} by {$SERVICE}.rollup(avg, $DURATION)"}, config.Indicators["http_response_time"])
+	assert.Equal(t, Indicator{
+		Query:          "avg:kubernetes.cpu.usage.total{service:$SERVICE}",
+		Aggregation:    "p95",
+		Timeout:        30 * time.Second,
+		MissingData:    MissingDataFail,
+		UnitConversion: &UnitConversion{From: "ns", To: "ms"},
+		FallbackQuery:  "avg:container.cpu.usage{service:$SERVICE}",
+	}, config.Indicators["cpu_p95"])
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "no indicators", content: "spec_version: '1.0'\n"},
+		{name: "unknown spec version", content: "spec_version: '3.0'\nindicators:\n  cpu: avg:cpu{*}\n"},
+		{name: "missing query", content: "spec_version: '2.0'\nindicators:\n  cpu:\n    aggregation: max\n"},
+		{name: "unknown aggregation", content: "spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    aggregation: mean\n"},
+		{name: "unknown missing data policy", content: "spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    missing_data: ignore\n"},
+		{name: "invalid timeout", content: "spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    timeout: soon\n"},
+		{name: "incompatible units", content: "spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    unit_conversion:\n      from: ms\n      to: MB\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+type fakeResourceGetter struct {
+	project, stage, service string
+}
+
+func resource(content string) (*models.Resource, error) {
+	if content == "" {
+		return nil, errors.New("Resource not found")
+	}
+	return &models.Resource{ResourceContent: content}, nil
+}
+
+func (f fakeResourceGetter) GetProjectResource(string, string) (*models.Resource, error) {
+	return resource(f.project)
+}
+
+func (f fakeResourceGetter) GetStageResource(string, string, string) (*models.Resource, error) {
+	return resource(f.stage)
+}
+
+func (f fakeResourceGetter) GetServiceResource(string, string, string, string) (*models.Resource, error) {
+	return resource(f.service)
+}
+
+func TestGetConfigurationMergesLevels(t *testing.T) {
+	resources := fakeResourceGetter{
+		project: "indicators:\n  cpu: avg:cpu{*}\n  memory: avg:memory{*}\n",
+		service: "spec_version: '2.0'\nindicators:\n  cpu:\n    query: max:cpu{service:$SERVICE}\n    aggregation: max\n",
+	}
+
+	config, err := GetConfiguration(resources, "podtatohead", "hardening", "helloservice", "datadog/sli.yaml")
+	require.NoError(t, err)
+	indicators := config.Indicators
+
+	assert.Equal(t, Indicator{Query: "max:cpu{service:$SERVICE}", Aggregation: AggregationMax}, indicators["cpu"])
+	assert.Equal(t, Indicator{Query: "avg:memory{*}"}, indicators["memory"])
+	// indicators that aren't configured fall back to the built-in ones
+	assert.Equal(t, DefaultIndicators()["throughput"], indicators["throughput"])
+}
+
+func TestParseConfigUnknownKeys(t *testing.T) {
+	_, err := ParseConfig([]byte("spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    agregation: max\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown keys 'agregation' (line 5)")
+
+	_, err = ParseConfig([]byte("spec_version: '2.0'\nindicator:\n  cpu: avg:cpu{*}\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown keys 'indicator' (line 2)")
+
+	// flat files are parsed as before, extra top-level keys are ignored
+	config, err := ParseConfig([]byte("spec_version: '1.0'\nfilters: {}\nindicators:\n  cpu: avg:cpu{*}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Indicator{Query: "avg:cpu{*}"}, config.Indicators["cpu"])
+
+	_, err = ParseConfig([]byte("spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    unit_conversion:\n      from: ms\n      into: s\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown keys 'into' (line 7)")
+}
+
+func TestParseConfigV2Indicators(t *testing.T) {
+	config, err := ParseConfig([]byte(`---
+spec_version: '2.0'
+indicators:
+  error_rate:
+    api_version: v2
+    queries:
+      errors: sum:trace.http.request.errors{service:$SERVICE}.as_count()
+      requests: sum:trace.http.request.hits{service:$SERVICE}.as_count()
+    formula: errors / requests * 100
+    query_aggregator: sum
+  throughput:
+    api_version: v2
+    queries:
+      hits: sum:trace.http.request.hits{service:$SERVICE}.as_count()
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "errors / requests * 100", config.Indicators["error_rate"].Formula)
+	assert.Equal(t, "sum", config.Indicators["error_rate"].QueryAggregator)
+	assert.Equal(t, "hits", config.Indicators["throughput"].Formula)
+
+	for _, invalid := range []string{
+		"spec_version: '2.0'\nindicators:\n  x:\n    api_version: v2\n    query: avg:cpu{*}\n",
+		"spec_version: '2.0'\nindicators:\n  x:\n    api_version: v2\n    queries:\n      a: avg:cpu{*}\n      b: avg:mem{*}\n",
+		"spec_version: '2.0'\nindicators:\n  x:\n    api_version: v2\n    queries:\n      a: avg:cpu{*}\n    query_aggregator: median\n",
+		"spec_version: '2.0'\nindicators:\n  x:\n    query: avg:cpu{*}\n    formula: a * 2\n",
+		"spec_version: '2.0'\nindicators:\n  x:\n    api_version: v3\n    query: avg:cpu{*}\n",
+	} {
+		_, err := ParseConfig([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGetConfigurationTimeout(t *testing.T) {
+	resources := fakeResourceGetter{
+		project: "spec_version: '2.0'\ntimeout: 10m\n",
+		service: "spec_version: '2.0'\nindicators:\n  cpu: avg:cpu{*}\n",
+	}
+
+	config, err := GetConfiguration(resources, "podtatohead", "hardening", "helloservice", "datadog/sli.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, config.Timeout)
+	assert.Equal(t, Indicator{Query: "avg:cpu{*}"}, config.Indicators["cpu"])
+
+	_, err = ParseConfig([]byte("spec_version: '2.0'\ntimeout: -1m\n"))
+	assert.EqualError(t, err, "negative timeout '-1m0s'")
+}
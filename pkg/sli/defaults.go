@@ -0,0 +1,19 @@
+package sli
+
+import (
+	// embed is required for the default indicators
+	_ "embed"
+)
+
+//go:embed defaults.yaml
+var defaultConfig []byte
+
+// DefaultIndicators returns the built-in indicators that are used if an indicator isn't defined in the SLI configuration
+func DefaultIndicators() map[string]Indicator {
+	config, err := ParseConfig(defaultConfig)
+	if err != nil {
+		// the default configuration is covered by tests
+		panic(err)
+	}
+	return config.Indicators
+}
@@ -0,0 +1,26 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultIndicators(t *testing.T) {
+	indicators := DefaultIndicators()
+
+	for _, name := range []string{"throughput", "error_rate", "response_time_p50", "response_time_p90", "response_time_p95", "cpu", "memory"} {
+		assert.Contains(t, indicators, name)
+	}
+	assert.Equal(t, APIVersionV2, indicators["error_rate"].APIVersion)
+	assert.Equal(t, "errors / hits * 100", indicators["error_rate"].Formula)
+}
+
+func TestGetConfigurationWithoutSLIFile(t *testing.T) {
+	config, err := GetConfiguration(fakeResourceGetter{}, "podtatohead", "hardening", "helloservice", "datadog/sli.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultIndicators(), config.Indicators)
+	assert.Zero(t, config.Timeout)
+}
@@ -0,0 +1,82 @@
+package sli
+
+import (
+	"sort"
+	"strings"
+)
+
+// InjectFilters adds the filters as key:value tags to every {...} scope of a Datadog query.
+// A {*} scope is replaced by the filters, grouping clauses like "by {service}" are left untouched
+// and filters whose key is already used in a scope are not added to it.
+func InjectFilters(query string, filters map[string]string) string {
+	if len(filters) == 0 {
+		return query
+	}
+
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result strings.Builder
+	rest := query
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			break
+		}
+		closing := strings.Index(rest[open:], "}")
+		if closing < 0 {
+			break
+		}
+		closing += open
+
+		result.WriteString(rest[:open+1])
+		scope := rest[open+1 : closing]
+		if isGroupBy(result.String()[:result.Len()-1]) {
+			result.WriteString(scope)
+		} else {
+			result.WriteString(injectScope(scope, keys, filters))
+		}
+		result.WriteString("}")
+		rest = rest[closing+1:]
+	}
+	result.WriteString(rest)
+	return result.String()
+}
+
+// isGroupBy checks if the text before a scope ends with the "by" keyword of a grouping clause
+func isGroupBy(prefix string) bool {
+	prefix = strings.ToLower(strings.TrimRight(prefix, " "))
+	if !strings.HasSuffix(prefix, "by") {
+		return false
+	}
+	prefix = strings.TrimSuffix(prefix, "by")
+	return prefix == "" || strings.HasSuffix(prefix, " ") || strings.HasSuffix(prefix, "}")
+}
+
+func injectScope(scope string, keys []string, filters map[string]string) string {
+	tags := []string{}
+	for _, tag := range strings.Split(scope, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "*" {
+			tags = append(tags, tag)
+		}
+	}
+
+	used := map[string]bool{}
+	for _, tag := range tags {
+		used[strings.TrimPrefix(strings.SplitN(tag, ":", 2)[0], "!")] = true
+	}
+
+	for _, key := range keys {
+		if !used[key] {
+			tags = append(tags, key+":"+filters[key])
+		}
+	}
+
+	if len(tags) == 0 {
+		return "*"
+	}
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,48 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectFilters(t *testing.T) {
+	filters := map[string]string{"region": "eu-west-1", "env": "prod"}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"avg:system.load.1{*}", "avg:system.load.1{env:prod,region:eu-west-1}"},
+		{"avg:cpu{service:carts}.rollup(avg, 300)", "avg:cpu{service:carts,env:prod,region:eu-west-1}.rollup(avg, 300)"},
+		{"avg:cpu{region:us-east-1} by {host}", "avg:cpu{region:us-east-1,env:prod} by {host}"},
+		{"sum:errors{*}.as_count() / sum:hits{*}.as_count()", "sum:errors{env:prod,region:eu-west-1}.as_count() / sum:hits{env:prod,region:eu-west-1}.as_count()"},
+		{"avg:cpu{!env:staging}by{service}", "avg:cpu{!env:staging,region:eu-west-1}by{service}"},
+		{"avg:standby{*}", "avg:standby{env:prod,region:eu-west-1}"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, InjectFilters(test.query, filters), test.query)
+	}
+
+	assert.Equal(t, "avg:cpu{*}", InjectFilters("avg:cpu{*}", nil))
+}
+
+func TestIndicatorRenderWithFilters(t *testing.T) {
+	c := testQueryContext()
+	c.Filters = map[string]string{"region": "eu-west-1", "SERVICE": "ignored"}
+
+	indicator, err := Indicator{Query: "avg:cpu{service:$SERVICE,region:$region,zone:{{ .Filters.region }}a}"}.Render(c)
+	require.NoError(t, err)
+	assert.Equal(t, "avg:cpu{service:carts,region:eu-west-1,zone:eu-west-1a}", indicator.Query)
+
+	c.Filters = map[string]string{"region": "eu-west-1"}
+	indicator, err = Indicator{
+		Query:         "avg:cpu{service:$SERVICE}",
+		Queries:       map[string]string{"errors": "sum:errors{*}"},
+		InjectFilters: true,
+	}.Render(c)
+	require.NoError(t, err)
+	assert.Equal(t, "avg:cpu{service:carts,region:eu-west-1}", indicator.Query)
+	assert.Equal(t, map[string]string{"errors": "sum:errors{region:eu-west-1}"}, indicator.Queries)
+}
@@ -0,0 +1,48 @@
+package sli
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MissingDataPolicy defines how an indicator is reported if its query returns no data.
+// Besides skip, zero and fail, a number can be used as fixed value.
+// Without a policy, the indicator is reported as failed SLI.
+type MissingDataPolicy string
+
+const (
+	// MissingDataSkip leaves the indicator out of the result
+	MissingDataSkip MissingDataPolicy = "skip"
+	// MissingDataZero reports 0 as the indicator value
+	MissingDataZero MissingDataPolicy = "zero"
+	// MissingDataFail fails the whole evaluation
+	MissingDataFail MissingDataPolicy = "fail"
+)
+
+// Validate checks if p is a known policy or a fixed value
+func (p MissingDataPolicy) Validate() error {
+	switch p {
+	case "", MissingDataSkip, MissingDataZero, MissingDataFail:
+		return nil
+	}
+
+	if _, ok := p.Value(); !ok {
+		return fmt.Errorf("unknown missing_data policy '%s', use skip, zero, fail or a number", p)
+	}
+	return nil
+}
+
+// Value returns the value reported by the zero and fixed value policies
+func (p MissingDataPolicy) Value() (float64, bool) {
+	if p == MissingDataZero {
+		return 0, true
+	}
+
+	// NaN and Inf parse as numbers, but the SLI result can't be marshalled with them
+	value, err := strconv.ParseFloat(string(p), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,43 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingDataPolicyValidate(t *testing.T) {
+	for _, policy := range []MissingDataPolicy{"", MissingDataSkip, MissingDataZero, MissingDataFail, "42", "-0.5"} {
+		assert.NoError(t, policy.Validate(), string(policy))
+	}
+
+	assert.EqualError(t, MissingDataPolicy("ignore").Validate(), "unknown missing_data policy 'ignore', use skip, zero, fail or a number")
+	for _, policy := range []MissingDataPolicy{"NaN", "nan", "Inf", "+Inf", "-Inf", "infinity"} {
+		assert.Error(t, policy.Validate(), string(policy))
+	}
+}
+
+func TestMissingDataPolicyValue(t *testing.T) {
+	value, ok := MissingDataZero.Value()
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, value)
+
+	value, ok = MissingDataPolicy("99.5").Value()
+	assert.True(t, ok)
+	assert.Equal(t, 99.5, value)
+
+	for _, policy := range []MissingDataPolicy{"", MissingDataSkip, MissingDataFail, "NaN", "-Inf"} {
+		_, ok = policy.Value()
+		assert.False(t, ok, string(policy))
+	}
+}
+
+func TestParseConfigFixedMissingDataValue(t *testing.T) {
+	config, err := ParseConfig([]byte("spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    missing_data: 42\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, MissingDataPolicy("42"), config.Indicators["cpu"].MissingData)
+	}
+
+	_, err = ParseConfig([]byte("spec_version: '2.0'\nindicators:\n  cpu:\n    query: avg:cpu{*}\n    missing_data: NaN\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package sli
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Series is a single time series returned for a metrics query
+type Series struct {
+	// Tags identify the series within a grouped query, e.g. service:helloservice
+	Tags []string
+	// Points are [timestamp, value] pairs as returned by Datadog, timestamps are in milliseconds
+	Points [][]*float64
+	// Interval is the time covered by a single point
+	Interval time.Duration
+}
+
+// SeriesAggregation defines how the values of several series are combined into the SLI value
+type SeriesAggregation string
+
+const (
+	SeriesAggregationAvg SeriesAggregation = "avg"
+	SeriesAggregationSum SeriesAggregation = "sum"
+	SeriesAggregationMin SeriesAggregation = "min"
+	SeriesAggregationMax SeriesAggregation = "max"
+)
+
+// Validate checks if a is a known series aggregation
+func (a SeriesAggregation) Validate() error {
+	switch a {
+	case "", SeriesAggregationAvg, SeriesAggregationSum, SeriesAggregationMin, SeriesAggregationMax:
+		return nil
+	}
+	return fmt.Errorf("unknown series aggregation '%s'", a)
+}
+
+func (a SeriesAggregation) apply(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		switch a {
+		case SeriesAggregationAvg, SeriesAggregationSum:
+			result += v
+		case SeriesAggregationMin:
+			result = math.Min(result, v)
+		case SeriesAggregationMax:
+			result = math.Max(result, v)
+		}
+	}
+
+	if a == SeriesAggregationAvg {
+		result /= float64(len(values))
+	}
+	return result
+}
+
+// SelectSeries returns the series matching all tags of the indicator scope, or all series if no scope is defined
+func (i Indicator) SelectSeries(series []Series) []Series {
+	if i.Scope == "" {
+		return series
+	}
+
+	selected := []Series{}
+	for _, s := range series {
+		if s.hasTags(strings.Split(i.Scope, ",")) {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+// Value computes the SLI value from the series returned for the indicator query.
+// Without a series aggregation, the first selected series that has data points is used.
+func (i Indicator) Value(series []Series) (float64, error) {
+	series = i.SelectSeries(series)
+	if len(series) == 0 {
+		if i.Scope != "" {
+			return 0, fmt.Errorf("no series matches scope '%s': %w", i.Scope, ErrNoDataPoints)
+		}
+		return 0, ErrNoDataPoints
+	}
+
+	values := []float64{}
+	for _, s := range series {
+		value, err := i.Aggregation.Apply(s.Points)
+		if errors.Is(err, ErrNoDataPoints) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if i.SeriesAggregation == "" {
+			return value, nil
+		}
+		values = append(values, value)
+	}
+
+	if len(values) == 0 {
+		return 0, ErrNoDataPoints
+	}
+	return i.SeriesAggregation.apply(values), nil
+}
+
+func (s Series) hasTags(tags []string) bool {
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		found := false
+		for _, t := range s.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
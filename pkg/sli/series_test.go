@@ -0,0 +1,72 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var groupedSeries = []Series{
+	{Tags: []string{"service:cartservice", "env:prod"}, Points: points(value(10), value(20))},
+	{Tags: []string{"service:helloservice", "env:prod"}, Points: points(value(1), value(3))},
+	{Tags: []string{"service:helloservice", "env:dev"}, Points: points(nil, value(5))},
+}
+
+func TestIndicatorValueUsesFirstSeriesByDefault(t *testing.T) {
+	got, err := Indicator{}.Value(groupedSeries)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, got)
+}
+
+func TestIndicatorValueSkipsSeriesWithoutData(t *testing.T) {
+	series := append([]Series{{Tags: []string{"service:emptyservice"}, Points: points(nil, nil)}}, groupedSeries...)
+
+	got, err := Indicator{}.Value(series)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, got)
+
+	_, err = Indicator{}.Value([]Series{{Points: points(nil)}, {Points: points()}})
+	assert.ErrorIs(t, err, ErrNoDataPoints)
+}
+
+func TestIndicatorValueWithSeriesAggregation(t *testing.T) {
+	tests := []struct {
+		aggregation SeriesAggregation
+		want        float64
+	}{
+		{aggregation: SeriesAggregationAvg, want: 28.0 / 3},
+		{aggregation: SeriesAggregationSum, want: 28},
+		{aggregation: SeriesAggregationMin, want: 3},
+		{aggregation: SeriesAggregationMax, want: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.aggregation), func(t *testing.T) {
+			got, err := Indicator{SeriesAggregation: tt.aggregation}.Value(groupedSeries)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestIndicatorValueWithScope(t *testing.T) {
+	got, err := Indicator{Scope: "service:helloservice, env:dev"}.Value(groupedSeries)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, got)
+
+	got, err = Indicator{Scope: "service:helloservice", SeriesAggregation: SeriesAggregationMax, Aggregation: AggregationAvg}.Value(groupedSeries)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, got)
+
+	_, err = Indicator{Scope: "service:unknown"}.Value(groupedSeries)
+	assert.Error(t, err)
+}
+
+func TestIndicatorValueWithoutSeries(t *testing.T) {
+	_, err := Indicator{}.Value(nil)
+	assert.ErrorIs(t, err, ErrNoDataPoints)
+
+	_, err = Indicator{SeriesAggregation: SeriesAggregationSum}.Value([]Series{{Points: points(nil)}})
+	assert.ErrorIs(t, err, ErrNoDataPoints)
+}
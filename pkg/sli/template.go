@@ -0,0 +1,167 @@
+package sli
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// QueryContext holds the values that can be used in the queries of an indicator.
+// Queries can either use Go templates, e.g. {{ .Labels.version | default "unknown" }},
+// or the $PROJECT, $STAGE, $SERVICE, $DEPLOYMENT, $KEPTN_CONTEXT, $START, $END, $DURATION
+// and $DURATION_MINUTES placeholders. The custom filters of the SLO are available as
+// {{ .Filters.<key> }} and $<key>.
+type QueryContext struct {
+	Project      string
+	Stage        string
+	Service      string
+	Deployment   string
+	KeptnContext string
+	Labels       map[string]string
+	Filters      map[string]string
+	// Start and End of the evaluation as unix timestamps in seconds
+	Start int64
+	End   int64
+	// Duration of the evaluation in seconds
+	Duration int64
+	// DurationMinutes is the duration of the evaluation in minutes, rounded up
+	DurationMinutes int64
+}
+
+// NewQueryContext creates a QueryContext for the evaluation between start and end
+func NewQueryContext(project, stage, service string, start, end time.Time) QueryContext {
+	seconds := int64(math.Ceil(end.Sub(start).Seconds()))
+	return QueryContext{
+		Project:         project,
+		Stage:           stage,
+		Service:         service,
+		Labels:          map[string]string{},
+		Filters:         map[string]string{},
+		Start:           start.Unix(),
+		End:             end.Unix(),
+		Duration:        seconds,
+		DurationMinutes: int64(math.Ceil(float64(seconds) / 60)),
+	}
+}
+
+// WithWindow returns a copy of c for the evaluation between start and end
+func (c QueryContext) WithWindow(start, end time.Time) QueryContext {
+	window := NewQueryContext(c.Project, c.Stage, c.Service, start, end)
+	c.Start, c.End, c.Duration, c.DurationMinutes = window.Start, window.End, window.Duration, window.DurationMinutes
+	return c
+}
+
+var invalidTagCharacters = regexp.MustCompile(`[^a-z0-9_\-:./]`)
+
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"replace": func(old, new, value string) string {
+		return strings.ReplaceAll(value, old, new)
+	},
+	"tagEscape": TagEscape,
+}
+
+// TagEscape converts value to a valid Datadog tag value: it is lowercased and
+// all characters besides alphanumerics, underscores, minuses, colons, periods and slashes are replaced by underscores
+func TagEscape(value string) string {
+	return invalidTagCharacters.ReplaceAllString(strings.ToLower(value), "_")
+}
+
+// Render executes the Go template in query and replaces the $ placeholders
+func (c QueryContext) Render(query string) (string, error) {
+	if strings.Contains(query, "{{") {
+		tmpl, err := template.New("query").Funcs(templateFuncs).Option("missingkey=zero").Parse(query)
+		if err != nil {
+			return "", fmt.Errorf("invalid query template: %w", err)
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, c); err != nil {
+			return "", fmt.Errorf("unable to render query template: %w", err)
+		}
+		query = rendered.String()
+	}
+
+	placeholders := map[string]string{
+		"$PROJECT":          c.Project,
+		"$STAGE":            c.Stage,
+		"$SERVICE":          c.Service,
+		"$project":          c.Project,
+		"$stage":            c.Stage,
+		"$service":          c.Service,
+		"$DEPLOYMENT":       c.Deployment,
+		"$KEPTN_CONTEXT":    c.KeptnContext,
+		"$START":            strconv.FormatInt(c.Start, 10),
+		"$END":              strconv.FormatInt(c.End, 10),
+		"$DURATION":         strconv.FormatInt(c.Duration, 10),
+		"$DURATION_MINUTES": strconv.FormatInt(c.DurationMinutes, 10),
+	}
+	for key, value := range c.Filters {
+		if _, ok := placeholders["$"+key]; !ok {
+			placeholders["$"+key] = value
+		}
+	}
+
+	// longer placeholders first, so e.g. $DURATION doesn't replace the beginning of $DURATION_MINUTES
+	names := make([]string, 0, len(placeholders))
+	for name := range placeholders {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if len(names[a]) != len(names[b]) {
+			return len(names[a]) > len(names[b])
+		}
+		return names[a] < names[b]
+	})
+
+	oldnew := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		oldnew = append(oldnew, name, placeholders[name])
+	}
+	return strings.NewReplacer(oldnew...).Replace(query), nil
+}
+
+// Render replaces the placeholders in all queries and the scope of the indicator
+// and injects the custom filters into the queries if the indicator enables InjectFilters
+func (i Indicator) Render(c QueryContext) (Indicator, error) {
+	var err error
+	if i.Query, err = c.Render(i.Query); err != nil {
+		return i, err
+	}
+	if i.Scope, err = c.Render(i.Scope); err != nil {
+		return i, err
+	}
+	if i.FallbackQuery, err = c.Render(i.FallbackQuery); err != nil {
+		return i, err
+	}
+
+	queries := make(map[string]string, len(i.Queries))
+	for name, query := range i.Queries {
+		if queries[name], err = c.Render(query); err != nil {
+			return i, fmt.Errorf("query '%s': %w", name, err)
+		}
+	}
+	i.Queries = queries
+
+	if i.InjectFilters {
+		i.Query = InjectFilters(i.Query, c.Filters)
+		i.FallbackQuery = InjectFilters(i.FallbackQuery, c.Filters)
+		for name, query := range i.Queries {
+			i.Queries[name] = InjectFilters(query, c.Filters)
+		}
+	}
+	return i, nil
+}
@@ -0,0 +1,69 @@
+package sli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testQueryContext() QueryContext {
+	c := NewQueryContext("sockshop", "staging", "carts", time.Unix(1600000000, 0), time.Unix(1600000330, 0))
+	c.Deployment = "carts-canary"
+	c.KeptnContext = "a1b2c3"
+	c.Labels = map[string]string{"version": "1.2.0", "team": "Shop Team"}
+	return c
+}
+
+func TestRenderPlaceholders(t *testing.T) {
+	c := testQueryContext()
+
+	query, err := c.Render("avg:cpu{project:$PROJECT,stage:$stage,service:$SERVICE}.rollup(avg, $DURATION)")
+	require.NoError(t, err)
+	assert.Equal(t, "avg:cpu{project:sockshop,stage:staging,service:carts}.rollup(avg, 330)", query)
+
+	query, err = c.Render("$START $END $DURATION_MINUTES $DEPLOYMENT $KEPTN_CONTEXT")
+	require.NoError(t, err)
+	assert.Equal(t, "1600000000 1600000330 6 carts-canary a1b2c3", query)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	c := testQueryContext()
+
+	query, err := c.Render(`avg:cpu{service:{{ .Service }},version:{{ .Labels.version }},team:{{ .Labels.team | tagEscape }}}.rollup(avg, {{ .Duration }})`)
+	require.NoError(t, err)
+	assert.Equal(t, "avg:cpu{service:carts,version:1.2.0,team:shop_team}.rollup(avg, 330)", query)
+
+	query, err = c.Render(`avg:cpu{owner:{{ .Labels.owner | default "none" }},stage:{{ .Stage | replace "ing" "" | upper }},{{ lower "ENV" }}:$STAGE}`)
+	require.NoError(t, err)
+	assert.Equal(t, "avg:cpu{owner:none,stage:STAG,env:staging}", query)
+
+	_, err = c.Render("avg:cpu{service:{{ .Service }")
+	assert.Error(t, err)
+
+	_, err = c.Render("avg:cpu{service:{{ .Unknown }}}")
+	assert.Error(t, err)
+}
+
+func TestIndicatorRender(t *testing.T) {
+	indicator, err := Indicator{
+		Query:         "avg:cpu{service:$SERVICE}",
+		Scope:         "version:{{ .Labels.version }}",
+		FallbackQuery: "avg:cpu{$STAGE}",
+		Queries:       map[string]string{"a": "sum:errors{service:{{ .Service }}}"},
+	}.Render(testQueryContext())
+	require.NoError(t, err)
+
+	assert.Equal(t, "avg:cpu{service:carts}", indicator.Query)
+	assert.Equal(t, "version:1.2.0", indicator.Scope)
+	assert.Equal(t, "avg:cpu{staging}", indicator.FallbackQuery)
+	assert.Equal(t, map[string]string{"a": "sum:errors{service:carts}"}, indicator.Queries)
+
+	_, err = Indicator{Queries: map[string]string{"a": "{{ .Unknown }}"}}.Render(testQueryContext())
+	assert.Error(t, err)
+}
+
+func TestTagEscape(t *testing.T) {
+	assert.Equal(t, "my_app/v1.2-rc:1", TagEscape("My App/v1.2-rc:1"))
+}
@@ -0,0 +1,89 @@
+package sli
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnitConversion converts SLI values between two units of the same kind, e.g. from ns to ms
+type UnitConversion struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type unit struct {
+	kind   string
+	factor float64
+}
+
+// units maps the supported units to their kind and their factor relative to the base unit of the kind
+var units = map[string]unit{
+	"ns":       {kind: "time", factor: 1e-9},
+	"us":       {kind: "time", factor: 1e-6},
+	"ms":       {kind: "time", factor: 1e-3},
+	"s":        {kind: "time", factor: 1},
+	"min":      {kind: "time", factor: 60},
+	"h":        {kind: "time", factor: 3600},
+	"B":        {kind: "data", factor: 1},
+	"KB":       {kind: "data", factor: 1e3},
+	"MB":       {kind: "data", factor: 1e6},
+	"GB":       {kind: "data", factor: 1e9},
+	"KiB":      {kind: "data", factor: 1 << 10},
+	"MiB":      {kind: "data", factor: 1 << 20},
+	"GiB":      {kind: "data", factor: 1 << 30},
+	"fraction": {kind: "ratio", factor: 1},
+	"percent":  {kind: "ratio", factor: 0.01},
+}
+
+// UnmarshalYAML rejects unknown keys
+func (u *UnitConversion) UnmarshalYAML(value *yaml.Node) error {
+	type plain UnitConversion
+	if err := checkKnownFields(value, plain{}); err != nil {
+		return err
+	}
+	return value.Decode((*plain)(u))
+}
+
+// Apply converts value from the source unit to the target unit
+func (u *UnitConversion) Apply(value float64) float64 {
+	if u == nil {
+		return value
+	}
+
+	factor, err := u.factor()
+	if err != nil {
+		return value
+	}
+	return value * factor
+}
+
+// Revert converts value from the target unit back to the source unit
+func (u *UnitConversion) Revert(value float64) float64 {
+	if u == nil {
+		return value
+	}
+
+	factor, err := u.factor()
+	if err != nil {
+		return value
+	}
+	return value / factor
+}
+
+func (u *UnitConversion) factor() (float64, error) {
+	from, ok := units[u.From]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit '%s'", u.From)
+	}
+
+	to, ok := units[u.To]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit '%s'", u.To)
+	}
+
+	if from.kind != to.kind {
+		return 0, fmt.Errorf("unable to convert %s to %s", u.From, u.To)
+	}
+	return from.factor / to.factor, nil
+}
@@ -0,0 +1,25 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitConversionApply(t *testing.T) {
+	assert.InDelta(t, 1.5, (&UnitConversion{From: "ns", To: "ms"}).Apply(1.5e6), 1e-9)
+	assert.InDelta(t, 2, (&UnitConversion{From: "s", To: "min"}).Apply(120), 1e-9)
+	assert.InDelta(t, 1, (&UnitConversion{From: "KiB", To: "MiB"}).Apply(1024), 1e-9)
+	assert.InDelta(t, 12.5, (&UnitConversion{From: "fraction", To: "percent"}).Apply(0.125), 1e-9)
+
+	var none *UnitConversion
+	assert.Equal(t, 42.0, none.Apply(42))
+}
+
+func TestUnitConversionRevert(t *testing.T) {
+	assert.InDelta(t, 1.5e6, (&UnitConversion{From: "ns", To: "ms"}).Revert(1.5), 1e-3)
+	assert.InDelta(t, 0.125, (&UnitConversion{From: "fraction", To: "percent"}).Revert(12.5), 1e-9)
+
+	var none *UnitConversion
+	assert.Equal(t, 42.0, none.Revert(42))
+}
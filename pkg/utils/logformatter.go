@@ -1,15 +1,31 @@
 package utils
 
-import logger "github.com/sirupsen/logrus"
+import (
+	"fmt"
+
+	logger "github.com/sirupsen/logrus"
+)
 
 type Formatter struct {
 	Fields           logger.Fields
 	BuiltinFormatter logger.Formatter
+	// Redactor masks secrets in the message and the fields of every entry, it may be nil
+	Redactor *Redactor
 }
 
 func (f *Formatter) Format(entry *logger.Entry) ([]byte, error) {
 	for k, v := range f.Fields {
 		entry.Data[k] = v
 	}
+
+	if f.Redactor != nil {
+		entry.Message = f.Redactor.Redact(entry.Message)
+		for k, v := range entry.Data {
+			switch v.(type) {
+			case string, error, fmt.Stringer:
+				entry.Data[k] = f.Redactor.Redact(fmt.Sprint(v))
+			}
+		}
+	}
 	return f.BuiltinFormatter.Format(entry)
 }
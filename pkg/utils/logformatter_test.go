@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	logger "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatterRedactsSecrets(t *testing.T) {
+	redactor := &Redactor{}
+	redactor.AddSecrets("0123456789abcdef")
+	formatter := &Formatter{
+		Fields:           logger.Fields{"service": "datadog-service"},
+		BuiltinFormatter: &logger.TextFormatter{DisableTimestamp: true},
+		Redactor:         redactor,
+	}
+
+	entry := logger.WithFields(logger.Fields{
+		"error": errors.New("request with key 0123456789abcdef failed"),
+		"count": 3,
+	})
+	entry.Message = "headers: map[Dd-Api-Key:[abc]]"
+
+	out, err := formatter.Format(entry)
+	require.NoError(t, err)
+	assert.Equal(t, `level=panic msg="headers: map[Dd-Api-Key:[[REDACTED]]]" count=3 error="request with key [REDACTED] failed" service=datadog-service`+"\n", string(out))
+}
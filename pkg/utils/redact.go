@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in log messages
+const Redacted = "[REDACTED]"
+
+// minSecretLength prevents short values like "1" from being masked everywhere in the logs
+const minSecretLength = 8
+
+// secretAssignment matches the values of Datadog key headers, query parameters and env vars,
+// e.g. DD-API-KEY: abc, Dd-Application-Key:[abc], api_key=abc or DD_APP_KEY="abc"
+var secretAssignment = regexp.MustCompile(`(?i)((?:dd[-_])?(?:api|app|application)[-_]?key["']?\s*[:=]\s*\[?["']?)([^\s"'\],;&}]+)`)
+
+// Redactor masks the Datadog keys in log messages, both by the names of the headers, parameters and env vars
+// they are passed in and by the values of all keys that were added as secrets
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets map[string]bool
+	// sorted are the secrets, the longest first, so a secret containing another one is masked completely
+	sorted []string
+}
+
+// AddSecrets registers values that are masked wherever they appear in a log message
+func (r *Redactor) AddSecrets(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.secrets == nil {
+		r.secrets = map[string]bool{}
+	}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) < minSecretLength || r.secrets[value] {
+			continue
+		}
+		r.secrets[value] = true
+		r.sorted = append(r.sorted, value)
+	}
+	sort.Slice(r.sorted, func(a, b int) bool {
+		return len(r.sorted[a]) > len(r.sorted[b])
+	})
+}
+
+// Redact returns s with all secrets masked
+func (r *Redactor) Redact(s string) string {
+	s = secretAssignment.ReplaceAllString(s, "${1}"+Redacted)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, secret := range r.sorted {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactKeyAssignments(t *testing.T) {
+	r := &Redactor{}
+
+	for input, expected := range map[string]string{
+		"DD-API-KEY: 0123456789abcdef":                   "DD-API-KEY: [REDACTED]",
+		"map[Dd-Api-Key:[abc] Dd-Application-Key:[xyz]]": "map[Dd-Api-Key:[[REDACTED]] Dd-Application-Key:[[REDACTED]]]",
+		"/api/v1/query?api_key=abc&application_key=def":  "/api/v1/query?api_key=[REDACTED]&application_key=[REDACTED]",
+		`DD_APP_KEY="abc"`:                               `DD_APP_KEY="[REDACTED]"`,
+		"avg:system.cpu.user{env:prod}":                  "avg:system.cpu.user{env:prod}",
+	} {
+		assert.Equal(t, expected, r.Redact(input), input)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	r := &Redactor{}
+	r.AddSecrets("0123456789abcdef", "0123456789abcdef-longer", "", "short")
+
+	assert.Equal(t, "keys [REDACTED] and [REDACTED]", r.Redact("keys 0123456789abcdef and 0123456789abcdef-longer"))
+	assert.Equal(t, "apiKeyAuth:{Key:[REDACTED] Prefix:}", r.Redact("apiKeyAuth:{Key:0123456789abcdef Prefix:}"))
+	assert.Equal(t, "short values are kept", r.Redact("short values are kept"))
+}